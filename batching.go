@@ -0,0 +1,128 @@
+package fastembed
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/sugarme/tokenizer"
+)
+
+// Private function to embed input strings in batches bounded by InitOptions.MaxBatchTokens
+// The inputs are tokenized first and sorted by token length, so that the inputs of a batch have similar lengths
+// and little padding, a long input no longer widening a whole batch of short ones.
+// The embeddings and their metadata are returned in the order of the inputs.
+func (f *FlagEmbedding) embedByTokens(ctx context.Context, input []string, batchSize int, normalized bool) ([]([]float32), []InputMetadata, error) {
+	encodings, tokenCounts, err := f.encodeEach(ctx, input, batchSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	embeddings, err := f.embedEncodings(ctx, encodings, batchSize, normalized)
+	if err != nil {
+		return nil, nil, err
+	}
+	return embeddings, newInputMetadata(encodings, tokenCounts), nil
+}
+
+// Private function to embed unpadded encodings, returning the embeddings in the order of the encodings
+// The encodings are grouped by token length when InitOptions.MaxBatchTokens is set, and batchSize at a time otherwise.
+// The embeddings are L2 normalized if normalized is true.
+func (f *FlagEmbedding) embedEncodings(ctx context.Context, encodings []tokenizer.Encoding, batchSize int, normalized bool) ([]([]float32), error) {
+	padding := f.tokenizer.GetPadding()
+	if padding == nil {
+		return nil, errors.New("the tokenizer has no padding")
+	}
+
+	var batches [][]int
+	if f.maxBatchTokens > 0 {
+		lengths := make([]int, len(encodings))
+		for i, encoding := range encodings {
+			lengths[i] = encoding.Len()
+		}
+		batches = tokenBatches(lengths, batchSize, f.maxBatchTokens)
+	} else {
+		for start := 0; start < len(encodings); start += batchSize {
+			batch := make([]int, 0, batchSize)
+			for i := start; i < min(start+batchSize, len(encodings)); i++ {
+				batch = append(batch, i)
+			}
+			batches = append(batches, batch)
+		}
+	}
+
+	embeddings := make([]([]float32), len(encodings))
+	err := f.runBatches(ctx, len(batches), 1, func(start, _ int) error {
+		indices := batches[start]
+		group := make([]tokenizer.Encoding, len(indices))
+		for i, index := range indices {
+			group[i] = encodings[index]
+		}
+
+		batchOut, err := f.embedBatch(newEncodedBatch(tokenizer.PadEncodings(group, *padding)), normalized)
+		if err != nil {
+			return err
+		}
+		// Every encoding belongs to a single batch, so the positions being accessed never overlap
+		for i, index := range indices {
+			embeddings[index] = batchOut[i]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// Private function to tokenize each input string on its own, without padding.
+// The inputs are tokenized by the workers of the model, batchSize at a time.
+// Returns the encodings and the number of tokens of each input before truncation.
+func (m *onnxModel) encodeEach(ctx context.Context, input []string, batchSize int) ([]tokenizer.Encoding, []int, error) {
+	encodings := make([]tokenizer.Encoding, len(input))
+	tokenCounts := make([]int, len(input))
+	err := m.runBatches(ctx, len(input), batchSize, func(start, end int) error {
+		for i := start; i < end; i++ {
+			encoding, tokenCount, err := m.encodeSingle(input[i])
+			if err != nil {
+				return err
+			}
+			encodings[i] = *encoding
+			tokenCounts[i] = tokenCount
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodings, tokenCounts, nil
+}
+
+// Private function to group inputs of the given token lengths into batches
+// The inputs are sorted by length, and a batch is closed when it holds batchSize inputs
+// or when adding the next input would take it over maxTokens tokens, padding included.
+// An input longer than maxTokens makes a batch of its own.
+// Returns the indices of the inputs of each batch.
+func tokenBatches(lengths []int, batchSize, maxTokens int) [][]int {
+	order := make([]int, len(lengths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return lengths[order[i]] < lengths[order[j]]
+	})
+
+	var batches [][]int
+	var current []int
+	for _, index := range order {
+		// The inputs are sorted, so the next input sets the padded length of the batch.
+		if len(current) > 0 && (len(current) == batchSize || (len(current)+1)*lengths[index] > maxTokens) {
+			batches = append(batches, current)
+			current = nil
+		}
+		current = append(current, index)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
@@ -0,0 +1,145 @@
+package fastembed_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestTokenBatches(t *testing.T) {
+	testCases := []struct {
+		name      string
+		lengths   []int
+		batchSize int
+		maxTokens int
+		expected  [][]int
+	}{
+		{
+			name:      "sorted by length",
+			lengths:   []int{10, 3, 5, 3},
+			batchSize: 256,
+			maxTokens: 1000,
+			expected:  [][]int{{1, 3, 2, 0}},
+		},
+		{
+			name:      "token budget",
+			lengths:   []int{100, 4, 4, 4, 50},
+			batchSize: 256,
+			maxTokens: 100,
+			expected:  [][]int{{1, 2, 3}, {4}, {0}},
+		},
+		{
+			name:      "batch size",
+			lengths:   []int{2, 2, 2, 2, 2},
+			batchSize: 2,
+			maxTokens: 1000,
+			expected:  [][]int{{0, 1}, {2, 3}, {4}},
+		},
+		{
+			name:      "input over the budget",
+			lengths:   []int{512, 8},
+			batchSize: 256,
+			maxTokens: 64,
+			expected:  [][]int{{1}, {0}},
+		},
+		{
+			name:      "no inputs",
+			batchSize: 256,
+			maxTokens: 64,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := fastembed.TokenBatches(tc.lengths, tc.batchSize, tc.maxTokens)
+			if !reflect.DeepEqual(batches, tc.expected) {
+				t.Errorf("Expected batches %v, got %v", tc.expected, batches)
+			}
+		})
+	}
+}
+
+// Returns a corpus of mostly short inputs with a few long ones, as found in practice.
+func mixedLengthCorpus(n int) []string {
+	corpus := make([]string, n)
+	for i := range corpus {
+		switch {
+		case i%64 == 0:
+			corpus[i] = strings.Repeat("A long document keeps going about many different topics. ", 50)
+		case i%8 == 0:
+			corpus[i] = strings.Repeat("A paragraph of medium length. ", 5)
+		default:
+			corpus[i] = "A short tweet about the weather"
+		}
+	}
+	return corpus
+}
+
+func TestEmbedMaxBatchTokens(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	budgeted, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{MaxBatchTokens: 2048})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer budgeted.Close()
+
+	input := mixedLengthCorpus(130)
+	expected, err := fe.Embed(input, 32)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	result, err := budgeted.Embed(input, 32)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The embeddings are returned in the order of the inputs, and do not depend on the padding.
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d embeddings, got %d", len(expected), len(result))
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if math.Abs(float64(result[i][j]-expected[i][j])) > 1e-4 {
+				t.Fatalf("Embedding %d mismatch at %d: expected %.6f, got %.6f", i, j, expected[i][j], result[i][j])
+			}
+		}
+	}
+}
+
+// Compares the throughput of count-only batches with token-budget batches on a mixed-length corpus.
+func BenchmarkEmbedMixedLengths(b *testing.B) {
+	corpus := mixedLengthCorpus(1024)
+	benchmarks := []struct {
+		name           string
+		maxBatchTokens int
+	}{
+		{name: "count", maxBatchTokens: 0},
+		{name: "tokens", maxBatchTokens: 8192},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{MaxBatchTokens: bm.maxBatchTokens})
+			if err != nil {
+				b.Fatalf("Expected no error, got %v", err)
+			}
+			defer fe.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := fe.Embed(corpus, 256); err != nil {
+					b.Fatalf("Expected no error, got %v", err)
+				}
+			}
+			b.ReportMetric(float64(len(corpus)*b.N)/b.Elapsed().Seconds(), "inputs/s")
+		})
+	}
+}
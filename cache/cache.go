@@ -0,0 +1,244 @@
+// Package cache manages the models downloaded into a fastembed cache directory.
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anush008/fastembed-go"
+)
+
+// The prefix of the directories of the Hugging Face Hub repositories, as in the Hugging Face cache.
+const huggingFacePrefix = "models--"
+
+// Enum-type representing the integrity of a cached model.
+type IntegrityStatus string
+
+const (
+	// The model files are present and match the digests of the model descriptor, if any.
+	StatusOK IntegrityStatus = "ok"
+	// Files of the model are missing.
+	StatusMissingFiles IntegrityStatus = "missing_files"
+	// Files of the model do not match the digests of the model descriptor.
+	StatusCorrupted IntegrityStatus = "corrupted"
+	// The model is neither built-in nor registered, so its files cannot be checked.
+	StatusUnknown IntegrityStatus = "unknown"
+)
+
+// Struct describing a model of the cache directory
+// Name: The name of the model, or the "owner/name" repository for the models of the Hugging Face Hub
+// Path: The directory of the model in the cache directory
+// Size: The size of the files of the model, in bytes
+// LastUsed: The last time the model was loaded, or downloaded
+// Status: The integrity of the model files
+// Err: The error found when checking the integrity, if any.
+type CachedModel struct {
+	Name     string
+	Path     string
+	Size     int64
+	LastUsed time.Time
+	Status   IntegrityStatus
+	Err      error
+}
+
+// Options to prune the cache directory
+// MaxAge: The models not used for longer are removed, none if 0
+// MaxSize: The least recently used models are removed until the cache holds at most this many bytes, none if 0.
+type PruneOptions struct {
+	MaxAge  time.Duration
+	MaxSize int64
+}
+
+// Function to list the models of a cache directory, the default one if empty, from the least recently used
+// The integrity of the models cached from the GCS archives is checked with fastembed.VerifyModel,
+// which hashes the files of the models whose descriptor has digests.
+// The integrity of the models of the Hugging Face Hub is checked by resolving the links of their snapshots.
+func ListCachedModels(cacheDir string) ([]CachedModel, error) {
+	if cacheDir == "" {
+		cacheDir = fastembed.DefaultCacheDir()
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []CachedModel{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	knownModels := make(map[string]bool)
+	for _, info := range fastembed.ListSupportedModels() {
+		knownModels[string(info.Model)] = true
+	}
+
+	models := make([]CachedModel, 0, len(entries))
+	for _, entry := range entries {
+		// Temporary directories, partial downloads and locks are hidden.
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		model, err := cachedModel(cacheDir, entry, knownModels)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].LastUsed.Before(models[j].LastUsed)
+	})
+	return models, nil
+}
+
+// Function to remove a model from a cache directory, the default one if empty.
+// The name is the one reported by ListCachedModels.
+func RemoveModel(cacheDir, name string) error {
+	if cacheDir == "" {
+		cacheDir = fastembed.DefaultCacheDir()
+	}
+
+	entry, err := entryName(name)
+	if err != nil {
+		return err
+	}
+	modelPath := filepath.Join(cacheDir, entry)
+	if _, err := os.Stat(modelPath); err != nil {
+		return err
+	}
+	return os.RemoveAll(modelPath)
+}
+
+// Function to remove the models of a cache directory, the default one if empty, that are too old or over the size budget.
+// Returns the removed models.
+func Prune(cacheDir string, options PruneOptions) ([]CachedModel, error) {
+	models, err := ListCachedModels(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var totalSize int64
+	for _, model := range models {
+		totalSize += model.Size
+	}
+
+	removed := make([]CachedModel, 0)
+	now := time.Now()
+	// The models are sorted from the least recently used, the first ones are removed first.
+	for _, model := range models {
+		tooOld := options.MaxAge > 0 && now.Sub(model.LastUsed) > options.MaxAge
+		overBudget := options.MaxSize > 0 && totalSize > options.MaxSize
+		if !tooOld && !overBudget {
+			continue
+		}
+		if err := os.RemoveAll(model.Path); err != nil {
+			return removed, err
+		}
+		totalSize -= model.Size
+		removed = append(removed, model)
+	}
+	return removed, nil
+}
+
+// Private function to describe the model of an entry of the cache directory.
+func cachedModel(cacheDir string, entry fs.DirEntry, knownModels map[string]bool) (CachedModel, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return CachedModel{}, err
+	}
+
+	modelPath := filepath.Join(cacheDir, entry.Name())
+	size, err := dirSize(modelPath)
+	if err != nil {
+		return CachedModel{}, err
+	}
+
+	model := CachedModel{
+		Name:     entry.Name(),
+		Path:     modelPath,
+		Size:     size,
+		LastUsed: info.ModTime(),
+	}
+
+	switch {
+	case strings.HasPrefix(entry.Name(), huggingFacePrefix):
+		model.Name = strings.ReplaceAll(strings.TrimPrefix(entry.Name(), huggingFacePrefix), "--", "/")
+		model.Err = checkSnapshots(modelPath)
+	case knownModels[entry.Name()]:
+		model.Err = fastembed.VerifyModel(cacheDir, fastembed.EmbeddingModel(entry.Name()))
+	default:
+		model.Status = StatusUnknown
+		return model, nil
+	}
+
+	var checksumErr *fastembed.ChecksumError
+	switch {
+	case model.Err == nil:
+		model.Status = StatusOK
+	case errors.As(model.Err, &checksumErr):
+		model.Status = StatusCorrupted
+	default:
+		model.Status = StatusMissingFiles
+	}
+	return model, nil
+}
+
+// Private function to return the name of the entry of the cache directory of a model.
+func entryName(name string) (string, error) {
+	entry := name
+	if owner, repo, found := strings.Cut(name, "/"); found {
+		for _, part := range []string{owner, repo} {
+			if part == "" || part == "." || part == ".." || strings.Contains(part, "/") {
+				return "", fmt.Errorf("invalid model name %q", name)
+			}
+		}
+		entry = huggingFacePrefix + owner + "--" + repo
+	}
+	if entry == "" || strings.HasPrefix(entry, ".") || !filepath.IsLocal(entry) || strings.ContainsRune(entry, filepath.Separator) {
+		return "", fmt.Errorf("invalid model name %q", name)
+	}
+	return entry, nil
+}
+
+// Private function to compute the size of the files of a directory, without following the links.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Private function to check that the files of the snapshots of a Hugging Face repository resolve to their blobs.
+func checkSnapshots(repoDir string) error {
+	snapshots := filepath.Join(repoDir, "snapshots")
+	if _, err := os.Stat(snapshots); err != nil {
+		return err
+	}
+	return filepath.WalkDir(snapshots, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if _, err := os.Stat(path); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,196 @@
+package cache_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anush008/fastembed-go"
+	"github.com/anush008/fastembed-go/cache"
+)
+
+func TestMain(m *testing.M) {
+	// A registered model whose digest no file matches.
+	err := fastembed.RegisterModel(fastembed.ModelDescriptor{
+		ModelInfo:  fastembed.ModelInfo{Model: "cache-test-model", Dim: 4},
+		FileSHA256: map[string]string{"model_optimized.onnx": strings.Repeat("0", 64)},
+	})
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// Writes a file of the given size, creating its directory.
+func writeFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Sets the last use time of an entry of the cache directory.
+func setLastUsed(t *testing.T, path string, lastUsed time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, lastUsed, lastUsed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Builds a cache directory holding a model of each kind, used from the oldest to the most recent:
+// a corrupted registered model, an unknown model, a Hugging Face repository and a built-in model.
+func buildCache(t *testing.T) string {
+	t.Helper()
+	cacheDir := t.TempDir()
+	now := time.Now()
+
+	writeFile(t, filepath.Join(cacheDir, "cache-test-model", "model_optimized.onnx"), 100)
+	setLastUsed(t, filepath.Join(cacheDir, "cache-test-model"), now.Add(-72*time.Hour))
+
+	writeFile(t, filepath.Join(cacheDir, "unknown-model", "model.onnx"), 200)
+	setLastUsed(t, filepath.Join(cacheDir, "unknown-model"), now.Add(-48*time.Hour))
+
+	repoDir := filepath.Join(cacheDir, "models--org--model")
+	writeFile(t, filepath.Join(repoDir, "blobs", "blob"), 300)
+	snapshot := filepath.Join(repoDir, "snapshots", "0123456789abcdef0123456789abcdef01234567")
+	if err := os.MkdirAll(snapshot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", "..", "blobs", "blob"), filepath.Join(snapshot, "model.onnx")); err != nil {
+		t.Skip("symbolic links are not supported:", err)
+	}
+	setLastUsed(t, repoDir, now.Add(-24*time.Hour))
+
+	builtinPath := filepath.Join(cacheDir, string(fastembed.BGESmallENV15))
+	writeFile(t, filepath.Join(builtinPath, "model_optimized.onnx"), 400)
+	setLastUsed(t, builtinPath, now)
+
+	// Partial downloads are not listed.
+	writeFile(t, filepath.Join(cacheDir, ".fast-bge-base-en.tar.gz.part"), 500)
+	return cacheDir
+}
+
+func TestListCachedModels(t *testing.T) {
+	cacheDir := buildCache(t)
+	models, err := cache.ListCachedModels(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []cache.CachedModel{
+		{Name: "cache-test-model", Size: 100, Status: cache.StatusCorrupted},
+		{Name: "unknown-model", Size: 200, Status: cache.StatusUnknown},
+		{Name: "org/model", Size: 300, Status: cache.StatusOK},
+		{Name: string(fastembed.BGESmallENV15), Size: 400, Status: cache.StatusOK},
+	}
+	if len(models) != len(expected) {
+		t.Fatalf("Expected %d models, got %+v", len(expected), models)
+	}
+	for i, model := range models {
+		if model.Name != expected[i].Name || model.Size != expected[i].Size || model.Status != expected[i].Status {
+			t.Errorf("Expected %+v, got %+v", expected[i], model)
+		}
+	}
+
+	// A snapshot whose blob was removed is missing files.
+	if err := os.Remove(filepath.Join(cacheDir, "models--org--model", "blobs", "blob")); err != nil {
+		t.Fatal(err)
+	}
+	models, err = cache.ListCachedModels(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if models[2].Status != cache.StatusMissingFiles {
+		t.Errorf("Expected %s, got %s", cache.StatusMissingFiles, models[2].Status)
+	}
+}
+
+func TestListCachedModelsDefaultDir(t *testing.T) {
+	cacheDir := buildCache(t)
+	t.Setenv("FASTEMBED_CACHE_PATH", cacheDir)
+	if fastembed.DefaultCacheDir() != cacheDir {
+		t.Fatalf("Expected the default cache directory to be %s, got %s", cacheDir, fastembed.DefaultCacheDir())
+	}
+
+	models, err := cache.ListCachedModels("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 4 {
+		t.Errorf("Expected 4 models, got %d", len(models))
+	}
+
+	t.Setenv("FASTEMBED_CACHE_PATH", filepath.Join(cacheDir, "missing"))
+	if models, err := cache.ListCachedModels(""); err != nil || len(models) != 0 {
+		t.Errorf("Expected no models, got %v, %v", models, err)
+	}
+}
+
+func TestRemoveModel(t *testing.T) {
+	cacheDir := buildCache(t)
+	for _, name := range []string{"org/model", "unknown-model"} {
+		if err := cache.RemoveModel(cacheDir, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	models, err := cache.ListCachedModels(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 {
+		t.Errorf("Expected 2 models left, got %+v", models)
+	}
+
+	if err := cache.RemoveModel(cacheDir, "unknown-model"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not exist error, got %v", err)
+	}
+	for _, name := range []string{"", "..", ".fast-bge-base-en.tar.gz.part", "../other", "org/../../model"} {
+		if err := cache.RemoveModel(cacheDir, name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected an invalid name error for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	testCases := map[string]struct {
+		options cache.PruneOptions
+		removed []string
+	}{
+		"nothing":  {options: cache.PruneOptions{}},
+		"max age":  {options: cache.PruneOptions{MaxAge: 36 * time.Hour}, removed: []string{"cache-test-model", "unknown-model"}},
+		"max size": {options: cache.PruneOptions{MaxSize: 500}, removed: []string{"cache-test-model", "unknown-model", "org/model"}},
+		"both": {
+			options: cache.PruneOptions{MaxAge: 60 * time.Hour, MaxSize: 1000},
+			removed: []string{"cache-test-model"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cacheDir := buildCache(t)
+			removed, err := cache.Prune(cacheDir, tc.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(removed) != len(tc.removed) {
+				t.Fatalf("Expected %v to be removed, got %+v", tc.removed, removed)
+			}
+			for i, model := range removed {
+				if model.Name != tc.removed[i] {
+					t.Errorf("Expected %s to be removed, got %s", tc.removed[i], model.Name)
+				}
+				if _, err := os.Stat(model.Path); !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("Expected %s to be deleted, got %v", model.Path, err)
+				}
+			}
+		})
+	}
+}
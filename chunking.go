@@ -0,0 +1,213 @@
+package fastembed
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/sugarme/tokenizer"
+)
+
+// Enum-type representing how EmbedLong combines the chunk embeddings of a document into a single vector.
+type ChunkAggregation string
+
+const (
+	// The mean of the chunk embeddings.
+	MeanAggregation ChunkAggregation = "mean"
+	// The mean of the chunk embeddings weighted by their number of tokens, so a short last chunk counts less.
+	TokenWeightedAggregation ChunkAggregation = "token_weighted"
+)
+
+// Options of EmbedLong
+// ChunkLength: The number of tokens of a chunk, the special tokens excluded,
+// defaults to and is capped by the most that fits in InitOptions.MaxLength
+// Stride: The number of tokens shared by consecutive chunks, so the text around a boundary is embedded with its context, none by default
+// Aggregation: How to combine the chunk embeddings of a document into LongEmbedding.Embedding, none by default
+// BatchSize: The number of chunks embedded in a single batch, defaults to 256
+type LongEmbedOptions struct {
+	ChunkLength int
+	Stride      int
+	Aggregation ChunkAggregation
+	BatchSize   int
+}
+
+// Struct holding the embedding of a chunk of a document
+// Start, End: The byte offsets of the chunk in the document, document[Start:End] being its text
+// TokenCount: The number of tokens of the chunk, the special tokens excluded
+type ChunkEmbedding struct {
+	Start      int
+	End        int
+	TokenCount int
+	Embedding  []float32
+}
+
+// Struct holding the embeddings of a document split into chunks
+// Chunks: The embeddings of the chunks, in the order of the document
+// Embedding: The chunk embeddings combined as set by LongEmbedOptions.Aggregation, nil without aggregation
+type LongEmbedding struct {
+	Chunks    []ChunkEmbedding
+	Embedding []float32
+}
+
+// Struct holding a chunk of a document, before it is embedded.
+type documentChunk struct {
+	document int
+	span     ChunkEmbedding
+	encoding tokenizer.Encoding
+}
+
+// Function to embed documents longer than InitOptions.MaxLength, which Embed truncates
+// Each document is split into chunks of tokens, overlapping by LongEmbedOptions.Stride tokens,
+// and every chunk is embedded with the special tokens of the model.
+// A document shorter than a chunk makes a single chunk, embedded as Embed would.
+func (f *FlagEmbedding) EmbedLong(input []string, options *LongEmbedOptions) ([]LongEmbedding, error) {
+	return f.EmbedLongContext(context.Background(), input, options)
+}
+
+// Function to embed documents longer than InitOptions.MaxLength, stopping early when the context is done.
+// See EmbedContext for the cancellation semantics.
+func (f *FlagEmbedding) EmbedLongContext(ctx context.Context, input []string, options *LongEmbedOptions) ([]LongEmbedding, error) {
+	if options == nil {
+		options = &LongEmbedOptions{}
+	}
+	batchSize := options.BatchSize
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	switch options.Aggregation {
+	case "", MeanAggregation, TokenWeightedAggregation:
+	default:
+		return nil, fmt.Errorf("unknown chunk aggregation %q", options.Aggregation)
+	}
+
+	// The special tokens take some of the maximum length.
+	chunkLength := f.tokenizer.GetTruncation().MaxLength
+	if postProcessor := f.tokenizer.GetPostProcessor(); postProcessor != nil {
+		chunkLength -= postProcessor.AddedTokens(false)
+	}
+	if options.ChunkLength > 0 {
+		chunkLength = min(chunkLength, options.ChunkLength)
+	}
+	if chunkLength <= 0 {
+		return nil, fmt.Errorf("model %s: no room for the tokens of a chunk", f.descriptor.Model)
+	}
+	if options.Stride < 0 || options.Stride >= chunkLength {
+		return nil, fmt.Errorf("the stride must be between 0 and the chunk length %d, got %d", chunkLength, options.Stride)
+	}
+
+	chunks, err := f.chunkDocuments(ctx, input, chunkLength, options.Stride, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	encodings := make([]tokenizer.Encoding, len(chunks))
+	for i, chunk := range chunks {
+		encodings[i] = chunk.encoding
+	}
+	embeddings, err := f.embedEncodings(ctx, encodings, batchSize, f.normalize)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]LongEmbedding, len(input))
+	for i, chunk := range chunks {
+		chunk.span.Embedding = embeddings[i]
+		results[chunk.document].Chunks = append(results[chunk.document].Chunks, chunk.span)
+	}
+	if options.Aggregation != "" {
+		for i := range results {
+			results[i].Embedding = aggregateChunks(results[i].Chunks, options.Aggregation, f.normalize)
+		}
+	}
+	return results, nil
+}
+
+// Private function to tokenize the documents and split them into chunks, in the order of the documents.
+// The documents are tokenized by the workers of the model, batchSize at a time.
+func (f *FlagEmbedding) chunkDocuments(ctx context.Context, input []string, chunkLength, stride, batchSize int) ([]documentChunk, error) {
+	chunksByDocument := make([][]documentChunk, len(input))
+	err := f.runBatches(ctx, len(input), batchSize, func(start, end int) error {
+		for i := start; i < end; i++ {
+			// Neither truncated nor post-processed, the special tokens are added to each chunk.
+			encoding, err := f.tokenizer.EncodeSingleSequence(tokenizer.NewInputSequence(input[i]), 0, tokenizer.Byte)
+			if err != nil {
+				return err
+			}
+			for _, window := range chunkWindows(encoding.Len(), chunkLength, stride) {
+				chunksByDocument[i] = append(chunksByDocument[i], f.newChunk(i, encoding, window[0], window[1]))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []documentChunk
+	for _, documentChunks := range chunksByDocument {
+		chunks = append(chunks, documentChunks...)
+	}
+	return chunks, nil
+}
+
+// Private function to create the chunk of the tokens between start and end of a document, special tokens added.
+func (f *FlagEmbedding) newChunk(document int, encoding *tokenizer.Encoding, start, end int) documentChunk {
+	span := ChunkEmbedding{TokenCount: end - start}
+	if end > start {
+		span.Start = encoding.Offsets[start][0]
+		span.End = encoding.Offsets[end-1][1]
+	}
+
+	// The slices are cloned as post-processing and padding append to them.
+	var words []int
+	if len(encoding.Words) == encoding.Len() {
+		words = slices.Clone(encoding.Words[start:end])
+	}
+	window := tokenizer.NewEncoding(
+		slices.Clone(encoding.Ids[start:end]),
+		slices.Clone(encoding.TypeIds[start:end]),
+		slices.Clone(encoding.Tokens[start:end]),
+		slices.Clone(encoding.Offsets[start:end]),
+		slices.Clone(encoding.SpecialTokenMask[start:end]),
+		slices.Clone(encoding.AttentionMask[start:end]),
+		nil,
+		tokenizer.WithWordsEncodingOpt(words),
+	)
+	return documentChunk{document: document, span: span, encoding: *f.addSpecialTokens(window)}
+}
+
+// Private function to split n tokens into windows of at most length tokens, consecutive windows sharing stride tokens.
+// Returns the start and end of each window, a single empty window for no tokens.
+func chunkWindows(n, length, stride int) [][2]int {
+	var windows [][2]int
+	for start := 0; ; start += length - stride {
+		end := min(start+length, n)
+		windows = append(windows, [2]int{start, end})
+		if end == n {
+			return windows
+		}
+	}
+}
+
+// Private function to combine the chunk embeddings of a document.
+func aggregateChunks(chunks []ChunkEmbedding, aggregation ChunkAggregation, normalized bool) []float32 {
+	combined := make([]float32, len(chunks[0].Embedding))
+	weightSum := float32(0.0)
+	for _, chunk := range chunks {
+		weight := float32(1.0)
+		if aggregation == TokenWeightedAggregation {
+			weight = float32(max(chunk.TokenCount, 1))
+		}
+		weightSum += weight
+		for j, val := range chunk.Embedding {
+			combined[j] += val * weight
+		}
+	}
+	for j := range combined {
+		combined[j] /= weightSum
+	}
+	if normalized {
+		combined = normalize(combined)
+	}
+	return combined
+}
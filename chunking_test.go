@@ -0,0 +1,149 @@
+package fastembed_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestChunkWindows(t *testing.T) {
+	testCases := []struct {
+		n, length, stride int
+		expected          [][2]int
+	}{
+		{n: 0, length: 4, stride: 0, expected: [][2]int{{0, 0}}},
+		{n: 3, length: 4, stride: 1, expected: [][2]int{{0, 3}}},
+		{n: 8, length: 4, stride: 0, expected: [][2]int{{0, 4}, {4, 8}}},
+		{n: 10, length: 4, stride: 2, expected: [][2]int{{0, 4}, {2, 6}, {4, 8}, {6, 10}}},
+		{n: 9, length: 4, stride: 1, expected: [][2]int{{0, 4}, {3, 7}, {6, 9}}},
+	}
+	for _, tc := range testCases {
+		if windows := fastembed.ChunkWindows(tc.n, tc.length, tc.stride); !reflect.DeepEqual(windows, tc.expected) {
+			t.Errorf("Expected windows %v for %d tokens by %d with a stride of %d, got %v", tc.expected, tc.n, tc.length, tc.stride, windows)
+		}
+	}
+}
+
+func TestChunkDocuments(t *testing.T) {
+	words := strings.Fields("one two three four five six seven")
+	// The chunks and their special tokens fill the maximum length.
+	fe, stop, err := fastembed.NewTokenizerOnlyEmbedding(writeWordLevelTokenizer(t, words), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	documents := []string{"one two three four five six seven", "two  four", ""}
+
+	spans, ids, err := fastembed.ChunkDocuments(fe, documents, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSpans := [][]fastembed.ChunkEmbedding{
+		{
+			{Start: 0, End: 13, TokenCount: 3},
+			{Start: 8, End: 23, TokenCount: 3},
+			{Start: 19, End: 33, TokenCount: 3},
+		},
+		{{Start: 0, End: 9, TokenCount: 2}},
+		{{Start: 0, End: 0, TokenCount: 0}},
+	}
+	if !reflect.DeepEqual(spans, expectedSpans) {
+		t.Errorf("Expected spans %v, got %v", expectedSpans, spans)
+	}
+	for i, documentSpans := range spans {
+		for _, span := range documentSpans {
+			text := documents[i][span.Start:span.End]
+			if len(strings.Fields(text)) != span.TokenCount {
+				t.Errorf("Expected %d words in the text of the chunk, got %q", span.TokenCount, text)
+			}
+		}
+	}
+
+	// Every chunk gets the special tokens.
+	expectedIDs := [][][]int{
+		{{1, 3, 4, 5, 2}, {1, 5, 6, 7, 2}, {1, 7, 8, 9, 2}},
+		{{1, 4, 6, 2}},
+		{{1, 2}},
+	}
+	if !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("Expected ids %v, got %v", expectedIDs, ids)
+	}
+}
+
+func TestAggregateChunks(t *testing.T) {
+	chunks := []fastembed.ChunkEmbedding{
+		{TokenCount: 3, Embedding: []float32{1, 0}},
+		{TokenCount: 1, Embedding: []float32{0, 1}},
+	}
+
+	testCases := []struct {
+		aggregation fastembed.ChunkAggregation
+		normalized  bool
+		expected    []float32
+	}{
+		{aggregation: fastembed.MeanAggregation, expected: []float32{0.5, 0.5}},
+		{aggregation: fastembed.TokenWeightedAggregation, expected: []float32{0.75, 0.25}},
+		{aggregation: fastembed.MeanAggregation, normalized: true, expected: []float32{float32(math.Sqrt2 / 2), float32(math.Sqrt2 / 2)}},
+	}
+	for _, tc := range testCases {
+		combined := fastembed.AggregateChunks(chunks, tc.aggregation, tc.normalized)
+		for i := range tc.expected {
+			if math.Abs(float64(combined[i]-tc.expected[i])) > 1e-6 {
+				t.Errorf("Expected %v for %s aggregation, got %v", tc.expected, tc.aggregation, combined)
+				break
+			}
+		}
+	}
+}
+
+func TestEmbedLong(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{MaxLength: 32})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	long := strings.Repeat("The tail of the contract must be embedded too. ", 20)
+	results, err := fe.EmbedLong([]string{"hello world", long}, &fastembed.LongEmbedOptions{
+		Stride:      8,
+		Aggregation: fastembed.TokenWeightedAggregation,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// A short document is embedded as Embed does.
+	expected, err := fe.Embed([]string{"hello world"}, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results[0].Chunks) != 1 {
+		t.Fatalf("Expected a single chunk, got %d", len(results[0].Chunks))
+	}
+	for i := range expected[0] {
+		if math.Abs(float64(results[0].Embedding[i]-expected[0][i])) > 1e-4 {
+			t.Fatalf("Element %d mismatch: expected %.6f, got %.6f", i, expected[0][i], results[0].Embedding[i])
+		}
+	}
+
+	chunks := results[1].Chunks
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the long document to be split, got %d chunks", len(chunks))
+	}
+	if chunks[0].Start != 0 || chunks[len(chunks)-1].End != len(strings.TrimSpace(long)) {
+		t.Errorf("Expected the chunks to cover the document, got %d to %d", chunks[0].Start, chunks[len(chunks)-1].End)
+	}
+	for i := 1; i < len(chunks); i++ {
+		if chunks[i].Start >= chunks[i-1].End {
+			t.Errorf("Expected chunk %d to overlap the previous one", i)
+		}
+	}
+
+	if _, err := fe.EmbedLong([]string{long}, &fastembed.LongEmbedOptions{Stride: 30}); err == nil {
+		t.Error("Expected an error for a stride as long as a chunk")
+	}
+}
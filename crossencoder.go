@@ -0,0 +1,152 @@
+package fastembed
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// The built-in cross-encoder models, to load with NewTextCrossEncoder.
+const (
+	BGERerankerBase   EmbeddingModel = "BAAI/bge-reranker-base"
+	MSMarcoMiniLML6V2 EmbeddingModel = "Xenova/ms-marco-MiniLM-L-6-v2"
+)
+
+// Struct to represent the relevance of a document to a query.
+// Index is the position of the document in the documents given to Rerank.
+type RerankResult struct {
+	Index    int
+	Document string
+	Score    float32
+}
+
+// Struct to interface with a cross-encoder model, scoring (query, document) pairs jointly.
+// The model outputs logits of shape (batch size, Dim), the first one being the relevance score.
+type TextCrossEncoder struct {
+	*onnxModel
+}
+
+// Function to initialize BGERerankerBase, MSMarcoMiniLML6V2 or a cross-encoder model registered with RegisterModel
+// The Dim of the model descriptor is the number of labels of the model, 1 for rerankers
+// such as bge-reranker or ms-marco MiniLM.
+// Pooling and Normalize in the options are ignored.
+func NewTextCrossEncoder(options *InitOptions) (*TextCrossEncoder, error) {
+	// The default model of withDefaultOptions is an embedding one.
+	if options == nil || options.Model == "" {
+		return nil, errors.New("a cross-encoder model is required")
+	}
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	descriptor, err := getModelDescriptor(options.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	modelPath, err := retrieveModel(descriptor, options)
+	if err != nil {
+		return nil, err
+	}
+	return loadTextCrossEncoder(modelPath, descriptor, options)
+}
+
+// Function to initialize a cross-encoder model from a local directory holding the ONNX and tokenizer files.
+// The descriptor does not need to be registered, options.Model and options.CacheDir are ignored.
+func NewTextCrossEncoderFromDir(dir string, descriptor ModelDescriptor, options *InitOptions) (*TextCrossEncoder, error) {
+	descriptor = descriptor.withDefaults()
+	if err := descriptor.validate(); err != nil {
+		return nil, err
+	}
+
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	options.Model = descriptor.Model
+	return loadTextCrossEncoder(dir, descriptor, options)
+}
+
+// Private function to load the files of a cross-encoder model.
+func loadTextCrossEncoder(modelPath string, descriptor ModelDescriptor, options *InitOptions) (*TextCrossEncoder, error) {
+	model, err := loadONNXModel(modelPath, descriptor, options, textGraphSpec([]int{2}, "logits"), 0)
+	if err != nil {
+		return nil, err
+	}
+	return &TextCrossEncoder{onnxModel: model}, nil
+}
+
+// Function to release the model session when it is no longer needed.
+// Calling Close more than once is a no-op.
+func (c *TextCrossEncoder) Close() error {
+	return c.close()
+}
+
+// Function to score each document against the query
+// Returns the results sorted by decreasing score.
+// The batchSize parameter controls the number of documents to score in a single batch
+// The batches are processed in parallel, by at most InitOptions.MaxConcurrentBatches workers
+// Default batch size is 256.
+func (c *TextCrossEncoder) Rerank(query string, documents []string, batchSize int) ([]RerankResult, error) {
+	return c.RerankContext(context.Background(), query, documents, batchSize)
+}
+
+// Function to score each document against the query, stopping early when the context is done.
+// See FlagEmbedding.EmbedContext for the cancellation semantics.
+func (c *TextCrossEncoder) RerankContext(ctx context.Context, query string, documents []string, batchSize int) ([]RerankResult, error) {
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	scores := make([]float32, len(documents))
+	err := c.runBatches(ctx, len(documents), batchSize, func(start, end int) error {
+		batchOut, err := c.onnxScore(query, documents[start:end])
+		if err != nil {
+			return err
+		}
+		copy(scores[start:end], batchOut)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rankDocuments(documents, scores), nil
+}
+
+// Private function to sort the documents by decreasing score, the documents with the same score keeping their order.
+func rankDocuments(documents []string, scores []float32) []RerankResult {
+	results := make([]RerankResult, len(documents))
+	for i, document := range documents {
+		results[i] = RerankResult{
+			Index:    i,
+			Document: document,
+			Score:    scores[i],
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results
+}
+
+// Private function to score a batch of documents against the query.
+func (c *TextCrossEncoder) onnxScore(query string, documents []string) ([]float32, error) {
+	batch, err := c.encodePairs(query, documents)
+	if err != nil {
+		return nil, err
+	}
+
+	dim := c.descriptor.Dim
+	data, err := c.run(batch, ort.NewShape(int64(batch.size), int64(dim)))
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]float32, batch.size)
+	for i := range scores {
+		scores[i] = data[i*dim]
+	}
+	return scores, nil
+}
@@ -0,0 +1,93 @@
+package fastembed_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestNewTextCrossEncoderRequiresModel(t *testing.T) {
+	if _, err := fastembed.NewTextCrossEncoder(nil); err == nil {
+		t.Error("Expected an error without a cross-encoder model")
+	}
+	if _, err := fastembed.NewTextCrossEncoder(&fastembed.InitOptions{Model: "not-a-model"}); err == nil {
+		t.Error("Expected an error for an unknown model")
+	}
+}
+
+func TestRankDocuments(t *testing.T) {
+	documents := []string{"a", "b", "c", "d"}
+	results := fastembed.RankDocuments(documents, []float32{0.1, 2.5, -1, 2.5})
+
+	// Index is the position in documents, and equal scores keep the order of the documents.
+	expected := []fastembed.RerankResult{
+		{Index: 1, Document: "b", Score: 2.5},
+		{Index: 3, Document: "d", Score: 2.5},
+		{Index: 0, Document: "a", Score: 0.1},
+		{Index: 2, Document: "c", Score: -1},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+}
+
+func TestEncodePairs(t *testing.T) {
+	words := strings.Fields("one two three four five six")
+	fe, stop, err := fastembed.NewTokenizerOnlyEmbedding(writeWordLevelTokenizer(t, words), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	ids, typeIDs, err := fastembed.EncodePairs(fe, "one two", []string{"three", "four five six"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The query comes first in every pair, and the token type ids tell the document apart.
+	expectedIDs := [][]int64{
+		{1, 3, 4, 2, 5, 2, 0, 0},
+		{1, 3, 4, 2, 6, 7, 8, 2},
+	}
+	expectedTypeIDs := [][]int64{
+		{0, 0, 0, 0, 1, 1, 0, 0},
+		{0, 0, 0, 0, 1, 1, 1, 1},
+	}
+	if !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("Expected ids %v, got %v", expectedIDs, ids)
+	}
+	if !reflect.DeepEqual(typeIDs, expectedTypeIDs) {
+		t.Errorf("Expected token type ids %v, got %v", expectedTypeIDs, typeIDs)
+	}
+}
+
+func TestRerank(t *testing.T) {
+	reranker, err := fastembed.NewTextCrossEncoder(&fastembed.InitOptions{Model: fastembed.MSMarcoMiniLML6V2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer reranker.Close()
+
+	documents := []string{
+		"Bananas are yellow and rich in potassium.",
+		"Paris is the capital and largest city of France.",
+		"The Eiffel Tower is in Paris.",
+	}
+	results, err := reranker.Rerank("What is the capital of France?", documents, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(results) != len(documents) {
+		t.Fatalf("Expected %d results, got %d", len(documents), len(results))
+	}
+	if results[0].Index != 1 || results[len(results)-1].Index != 0 {
+		t.Errorf("Expected the capital first and the bananas last, got %+v", results)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Score > results[i-1].Score {
+			t.Errorf("Expected decreasing scores, got %+v", results)
+		}
+	}
+}
@@ -0,0 +1,517 @@
+package fastembed
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The base URL the built-in models are downloaded from.
+const defaultBaseURL = "https://storage.googleapis.com/qdrant-fastembed"
+
+// The longest wait between two download attempts.
+const maxDownloadBackoff = 30 * time.Second
+
+const (
+	// How long the default client waits for a connection to the server.
+	dialTimeout = 30 * time.Second
+	// How long the default client waits for the response headers once the request is sent.
+	responseHeaderTimeout = time.Minute
+)
+
+// The client of the downloads when InitOptions.HTTPClient is not set.
+var defaultHTTPClient = newDefaultHTTPClient()
+
+// Private function to create the default client of the downloads
+// The connection and the response headers are awaited for a bounded time, so an unreachable server fails the attempt
+// and is retried. The body of a model takes as long as it needs, as large models download slowly on slow connections.
+func newDefaultHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return &http.Client{Transport: transport}
+}
+
+// Struct holding how to download the model archives.
+type downloader struct {
+	client   *http.Client
+	retries  int
+	backoff  time.Duration
+	progress ProgressFunc
+}
+
+// Error returned by a download that failed with an HTTP error status.
+type downloadStatusError struct {
+	statusCode int
+	status     string
+}
+
+func (e *downloadStatusError) Error() string {
+	return "model download failed: " + e.status
+}
+
+// Private function to retrieve the model from its source, InitOptions.Source if set, else the one of the descriptor
+// and the GCS archives by default
+// Returns the path to the model, recording its use for the cache management.
+func retrieveModel(descriptor ModelDescriptor, options *InitOptions) (string, error) {
+	source := options.Source
+	if source == nil {
+		source = descriptor.Source
+	}
+	if source == nil {
+		source = GCSSource{}
+	}
+
+	modelPath, err := fetchModel(source, descriptor, options)
+	if err != nil {
+		return "", err
+	}
+	touchCacheEntry(options.CacheDir, modelPath)
+	return modelPath, nil
+}
+
+// Private function to fetch a model from its source, holding a lock file of the cache directory if it is downloaded
+// so that a single process downloads it while the others wait, and then use the downloaded model.
+// A model found in the cache, as the source looks it up in offline mode, and the models of the sources reading
+// local files are fetched without the lock, so a read-only cache directory can be used.
+func fetchModel(source ModelSource, descriptor ModelDescriptor, options *InitOptions) (string, error) {
+	switch source.(type) {
+	case LocalSource, *LocalSource, FSSource, *FSSource:
+		return source.Fetch(descriptor, options)
+	}
+	if options.Offline {
+		return source.Fetch(descriptor, options)
+	}
+	cached := *options
+	cached.Offline = true
+	if modelPath, err := source.Fetch(descriptor, &cached); err == nil {
+		return modelPath, nil
+	}
+
+	if err := os.MkdirAll(options.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	lock, err := acquireLock(modelLockPath(options.CacheDir, descriptor.Model), options.LockTimeout)
+	if err != nil {
+		return "", fmt.Errorf("model %s: %w", descriptor.Model, err)
+	}
+	modelPath, err := source.Fetch(descriptor, options)
+	if releaseErr := lock.release(); err == nil {
+		err = releaseErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return modelPath, nil
+}
+
+// Private function to return the path of the lock file of a model in the cache directory
+// The file is named after a digest of the model name, which may hold "/" separators or characters invalid in file names.
+func modelLockPath(cacheDir string, model EmbeddingModel) string {
+	digest := sha256.Sum256([]byte(model))
+	return filepath.Join(cacheDir, "."+hex.EncodeToString(digest[:8])+".lock")
+}
+
+// Private function to record that a model was used, as the modification time of its entry of the cache directory.
+// Models outside of the cache directory are left untouched.
+func touchCacheEntry(cacheDir, modelPath string) {
+	relativePath, err := filepath.Rel(cacheDir, modelPath)
+	if err != nil || relativePath == "." || !filepath.IsLocal(relativePath) {
+		return
+	}
+	entry, _, _ := strings.Cut(filepath.ToSlash(relativePath), "/")
+	now := time.Now()
+	// The last use time is informational, failing to record it must not fail the model loading.
+	_ = os.Chtimes(filepath.Join(cacheDir, entry), now, now)
+}
+
+// Private function to create a downloader from the download options.
+func newDownloader(options *InitOptions) *downloader {
+	return &downloader{
+		client:   options.HTTPClient,
+		retries:  *options.DownloadRetries,
+		backoff:  time.Second,
+		progress: options.ProgressFunc,
+	}
+}
+
+// Private function to return the URL of the archive of a built-in model.
+func builtinModelURL(baseURL string, model EmbeddingModel) string {
+	// The MLE5Large model URL doesn't follow the same naming convention as the other models
+	// So, we tranform "fast-multilingual-e5-large" -> "intfloat-multilingual-e5-large" in the download URL
+	// The model directory name in the GCS storage is "fast-multilingual-e5-large", like the others
+	modelName := model
+	if model == MLE5Large {
+		modelName = "intfloat" + model[strings.Index(string(model), "-"):]
+	}
+	return fmt.Sprintf("%s/%s.tar.gz", strings.TrimSuffix(baseURL, "/"), modelName)
+}
+
+// Private function to download the model archive into a partial file of the cache directory, and extract it.
+// The archive and the extracted files are checked against the digests of the descriptor, if any.
+func (d *downloader) downloadModel(descriptor ModelDescriptor, cacheDir string) (string, error) {
+	model := descriptor.Model
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", err
+	}
+
+	// The partial file is kept when the download fails, so the next download resumes where this one stopped.
+	partPath := filepath.Join(cacheDir, "."+string(model)+".tar.gz.part")
+	if err := d.downloadFile(descriptor.URL, partPath, model, string(model), nil); err != nil {
+		return "", fmt.Errorf("downloading model %s: %w", model, err)
+	}
+
+	archive, err := os.Open(partPath)
+	if err != nil {
+		return "", err
+	}
+	var modelPath string
+	info, err := archive.Stat()
+	if err == nil {
+		modelPath, err = extractModel(archive, info.Size(), descriptor, cacheDir, d.progress)
+	}
+	archive.Close()
+
+	// A complete archive that cannot be extracted would fail again if resumed, so the next download starts over.
+	if removeErr := os.Remove(partPath); err == nil {
+		err = removeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return modelPath, nil
+}
+
+// Private function to download a file of a model, retrying with an exponential backoff.
+// Every attempt resumes from the data already in the file, using an HTTP range request.
+// The header is added to the requests, for authentication.
+func (d *downloader) downloadFile(url, path string, model EmbeddingModel, name string, header http.Header) error {
+	for attempt := 0; ; attempt++ {
+		retryable, err := d.downloadAttempt(url, path, model, name, header)
+		if err == nil {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			d.progress.report(Progress{Model: model, Phase: PhaseDownload, File: name, Done: info.Size(), Total: info.Size()})
+			return nil
+		}
+		if !retryable || attempt >= d.retries {
+			return err
+		}
+		time.Sleep(min(d.backoff<<attempt, maxDownloadBackoff))
+	}
+}
+
+// Private function to download the rest of a file once.
+// Returns whether the download should be retried if it failed.
+func (d *downloader) downloadAttempt(url, path string, model EmbeddingModel, name string, header http.Header) (bool, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return false, err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	switch start, total, ok := parseContentRange(response.Header.Get("Content-Range")); {
+	case response.StatusCode == http.StatusPartialContent && offset > 0:
+		if !ok || start != offset {
+			// Start over rather than appending at the wrong place.
+			return true, restartDownload(file, fmt.Errorf("unexpected content range %q", response.Header.Get("Content-Range")))
+		}
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		if ok && total == offset {
+			// The file was already complete.
+			return false, nil
+		}
+		// The remote file changed since the partial file was written.
+		return true, restartDownload(file, errors.New("the partial download does not match the remote file"))
+	case response.StatusCode >= 200 && response.StatusCode <= 299:
+		// The server ignored the range and sent the whole file.
+		if err := file.Truncate(0); err != nil {
+			return false, err
+		}
+		if offset, err = file.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		return true, &downloadStatusError{statusCode: response.StatusCode, status: response.Status}
+	default:
+		return false, &downloadStatusError{statusCode: response.StatusCode, status: response.Status}
+	}
+
+	size := int64(-1)
+	if response.ContentLength >= 0 {
+		size = offset + response.ContentLength
+	}
+	progress := &progressWriter{
+		progress: Progress{Model: model, Phase: PhaseDownload, File: name, Done: offset, Total: size},
+		report:   d.progress,
+	}
+	progress.start()
+
+	// A connection dropped mid-stream ends with an error, and the next attempt resumes from what was written.
+	if _, err := io.Copy(io.MultiWriter(file, progress), response.Body); err != nil {
+		return true, err
+	}
+	return false, file.Close()
+}
+
+// Private function to empty a partial file so the next attempt downloads the whole file, returning err.
+func restartDownload(file *os.File, err error) error {
+	if truncateErr := file.Truncate(0); truncateErr != nil {
+		return truncateErr
+	}
+	return err
+}
+
+// Private function to parse a "bytes start-end/total" or "bytes */total" Content-Range header.
+// The total is -1 when unknown.
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	rangeSpec, found := strings.CutPrefix(contentRange, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	byteRange, size, found := strings.Cut(rangeSpec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total := int64(-1)
+	if size != "*" {
+		var err error
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if byteRange == "*" {
+		return 0, total, true
+	}
+
+	first, _, found := strings.Cut(byteRange, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// Private function to extract a model archive of the given size into the cache directory, -1 if unknown.
+// The archive is extracted into a temporary directory first, and the model directory is renamed into place
+// only once the extraction and the checksum verification succeeded,
+// so an interrupted or corrupted download never leaves a partial model in the cache.
+func extractModel(tarball io.Reader, size int64, descriptor ModelDescriptor, cacheDir string, progress ProgressFunc) (string, error) {
+	model := descriptor.Model
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", err
+	}
+
+	// The temporary directory is in the cache directory, so that the rename stays on the same file system.
+	tempDir, err := os.MkdirTemp(cacheDir, "."+string(model)+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// The archive is hashed as it is extracted, instead of being stored to be hashed first.
+	archiveHash := sha256.New()
+	extractProgress := &progressWriter{
+		progress: Progress{Model: model, Phase: PhaseExtract, File: string(model), Total: size},
+		report:   progress,
+	}
+	extractProgress.start()
+	archiveReader := io.TeeReader(tarball, io.MultiWriter(archiveHash, extractProgress))
+	if err := untar(archiveReader, tempDir); err != nil {
+		return "", fmt.Errorf("extracting model %s: %w", model, err)
+	}
+	// The end of the gzip stream may be left unread by the extraction.
+	if _, err := io.Copy(io.Discard, archiveReader); err != nil {
+		return "", err
+	}
+	if descriptor.ArchiveSHA256 != "" {
+		if err := checkDigest(model, "", descriptor.ArchiveSHA256, hex.EncodeToString(archiveHash.Sum(nil))); err != nil {
+			return "", err
+		}
+	}
+	extractProgress.complete()
+
+	extracted := filepath.Join(tempDir, string(model))
+	if info, err := os.Stat(extracted); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("extracting model %s: the archive has no %s directory", model, model)
+	}
+	if err := verifyModelFiles(extracted, descriptor, progress); err != nil {
+		return "", err
+	}
+
+	modelPath := filepath.Join(cacheDir, string(model))
+	if err := os.Rename(extracted, modelPath); err != nil {
+		// Another download of the same model may have completed in the meantime.
+		if _, statErr := os.Stat(modelPath); statErr == nil {
+			return modelPath, nil
+		}
+		return "", err
+	}
+	return modelPath, nil
+}
+
+// Private function to untar the downloaded model from a .tar.gz file.
+// Entries resolving outside of the target directory are rejected, as are entries below a symbolic link,
+// so that an archive cannot write anywhere else than in the target directory.
+// Symbolic links must resolve inside the model directory they belong to.
+func untar(tarball io.Reader, target string) error {
+	archive, err := gzip.NewReader(tarball)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	tarReader := tar.NewReader(archive)
+	symlinks := make(map[string]bool)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := localArchivePath(header.Name, symlinks)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(target, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeArchiveFile(path, tarReader, header.FileInfo().Mode()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			// The link must point inside its model directory, relatively, so that the extracted model can be moved.
+			linkTarget := filepath.FromSlash(header.Linkname)
+			modelDir, _, _ := strings.Cut(name, string(filepath.Separator))
+			if modelDir == name || filepath.IsAbs(linkTarget) || !isLocalTo(modelDir, filepath.Join(filepath.Dir(name), linkTarget)) {
+				return fmt.Errorf("invalid symbolic link %s -> %s in archive", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(linkTarget, path); err != nil {
+				return err
+			}
+			symlinks[name] = true
+		}
+	}
+
+	// A link may go through other links, it is checked once they are all extracted.
+	for name := range symlinks {
+		if err := checkArchiveLink(target, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Private function to check that a symbolic link of the archive resolves inside its model directory,
+// following the links it goes through.
+func checkArchiveLink(target, name string) error {
+	modelDir, _, _ := strings.Cut(name, string(filepath.Separator))
+	root, err := filepath.EvalSymlinks(filepath.Join(target, modelDir))
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(target, name))
+	if err != nil {
+		return fmt.Errorf("invalid symbolic link %s in archive: %w", filepath.ToSlash(name), err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("invalid symbolic link %s in archive: it resolves outside of %s", filepath.ToSlash(name), modelDir)
+	}
+	return nil
+}
+
+// Private function to report whether a cleaned relative path is dir or below it.
+func isLocalTo(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// Private function to check that an archive entry stays inside the target directory, and does not go through a symbolic link.
+// Returns the entry path, relative to the target directory.
+func localArchivePath(entryName string, symlinks map[string]bool) (string, error) {
+	name := filepath.Clean(filepath.FromSlash(entryName))
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("invalid path %s in archive", entryName)
+	}
+
+	for parent := filepath.Dir(name); parent != "."; parent = filepath.Dir(parent) {
+		if symlinks[parent] {
+			return "", fmt.Errorf("invalid path %s in archive: %s is a symbolic link", entryName, parent)
+		}
+	}
+	if symlinks[name] {
+		return "", fmt.Errorf("duplicate path %s in archive", entryName)
+	}
+	return name, nil
+}
+
+// Private function to write a file of the archive with the permissions it was archived with.
+// The owner can always read and write the file, for the cache to be manageable.
+func writeArchiveFile(path string, reader io.Reader, mode fs.FileMode) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,454 @@
+package fastembed_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+// The descriptor of the model extracted by the tests.
+var testModelDescriptor = fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: "test-model", Dim: 4}}
+
+// Builds a .tar.gz archive from the given headers, regular files getting their content from contents.
+func buildArchive(t *testing.T, headers []tar.Header, contents map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, header := range headers {
+		content := contents[header.Name]
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(content))
+		}
+		if err := tarWriter.WriteHeader(&header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// Checks that the cache directory only holds the expected entries, so no temporary directory is left behind.
+func assertCacheEntries(t *testing.T, cacheDir string, expected ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries in the cache, got %v", len(expected), entries)
+	}
+	for i, entry := range entries {
+		if entry.Name() != expected[i] {
+			t.Errorf("Expected %s in the cache, got %s", expected[i], entry.Name())
+		}
+	}
+}
+
+func TestExtractModel(t *testing.T) {
+	archive := buildArchive(t, []tar.Header{
+		{Name: "test-model/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "test-model/config.json", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "test-model/onnx/run.sh", Typeflag: tar.TypeReg, Mode: 0755},
+		{Name: "test-model/model.onnx", Typeflag: tar.TypeSymlink, Linkname: "onnx/run.sh"},
+	}, map[string]string{
+		"test-model/config.json": "{}",
+		"test-model/onnx/run.sh": "#!/bin/sh",
+	})
+
+	cacheDir := t.TempDir()
+	modelPath, err := fastembed.ExtractModel(bytes.NewReader(archive), testModelDescriptor, cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelPath != filepath.Join(cacheDir, "test-model") {
+		t.Errorf("Expected the model in %s, got %s", filepath.Join(cacheDir, "test-model"), modelPath)
+	}
+	assertCacheEntries(t, cacheDir, "test-model")
+
+	content, err := os.ReadFile(filepath.Join(modelPath, "model.onnx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "#!/bin/sh" {
+		t.Errorf("Expected the symbolic link to resolve to run.sh, got %q", content)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(filepath.Join(modelPath, "onnx", "run.sh"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0755 {
+			t.Errorf("Expected mode 0755, got %v", info.Mode().Perm())
+		}
+	}
+}
+
+func TestExtractModelRejectsUnsafeArchives(t *testing.T) {
+	testCases := map[string][]tar.Header{
+		"parent traversal": {
+			{Name: "test-model/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "test-model/../../escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"absolute path": {
+			{Name: "/tmp/escaped", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"symbolic link outside": {
+			{Name: "test-model/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "test-model/link", Typeflag: tar.TypeSymlink, Linkname: "../../.."},
+		},
+		"symbolic link to the parent directory": {
+			{Name: "test-model/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "test-model/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+		},
+		"chained symbolic links": {
+			{Name: "test-model/sub/", Typeflag: tar.TypeDir, Mode: 0755},
+			// Each link stays in the model directory lexically, the second one leaves it through the first one.
+			{Name: "test-model/sub/up", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			{Name: "test-model/up2", Typeflag: tar.TypeSymlink, Linkname: "sub/up/.."},
+		},
+		"symbolic model directory": {
+			{Name: "other/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "test-model", Typeflag: tar.TypeSymlink, Linkname: "other"},
+		},
+		"absolute symbolic link": {
+			{Name: "test-model/link", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		},
+		"write through symbolic link": {
+			{Name: "test-model/sub/", Typeflag: tar.TypeDir, Mode: 0755},
+			{Name: "test-model/link", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+			{Name: "test-model/link/config.json", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+		"no model directory": {
+			{Name: "other-model/config.json", Typeflag: tar.TypeReg, Mode: 0644},
+		},
+	}
+
+	for name, headers := range testCases {
+		t.Run(name, func(t *testing.T) {
+			root := t.TempDir()
+			cacheDir := filepath.Join(root, "cache")
+			archive := buildArchive(t, headers, nil)
+
+			if _, err := fastembed.ExtractModel(bytes.NewReader(archive), testModelDescriptor, cacheDir); err == nil {
+				t.Fatal("Expected an error")
+			}
+			assertCacheEntries(t, cacheDir)
+			assertCacheEntries(t, root, "cache")
+		})
+	}
+}
+
+func TestExtractModelTruncatedArchive(t *testing.T) {
+	archive := buildArchive(t, []tar.Header{
+		{Name: "test-model/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "test-model/config.json", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "test-model/model.onnx", Typeflag: tar.TypeReg, Mode: 0644},
+	}, map[string]string{
+		"test-model/config.json": "{}",
+		"test-model/model.onnx":  string(bytes.Repeat([]byte("onnx"), 1024)),
+	})
+
+	cacheDir := t.TempDir()
+	if _, err := fastembed.ExtractModel(bytes.NewReader(archive[:len(archive)/2]), testModelDescriptor, cacheDir); err == nil {
+		t.Fatal("Expected an error")
+	}
+	// A partial model would otherwise be used from the cache forever.
+	assertCacheEntries(t, cacheDir)
+}
+
+// Response writer aborting the connection once limit bytes of the body are written.
+type droppingWriter struct {
+	http.ResponseWriter
+	limit int
+}
+
+func (w *droppingWriter) Write(data []byte) (int, error) {
+	if len(data) > w.limit {
+		data = data[:w.limit]
+	}
+	written, err := w.ResponseWriter.Write(data)
+	w.limit -= written
+	if err == nil && w.limit == 0 {
+		w.ResponseWriter.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	return written, err
+}
+
+// Server of an archive, dropping the first connections mid-stream.
+// The Range headers of the requests are recorded.
+type flakyServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	drops  int
+	ranges []string
+}
+
+func newFlakyServer(t *testing.T, archive []byte, drops int, supportRange bool) *flakyServer {
+	t.Helper()
+	s := &flakyServer{drops: drops}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		drop := s.drops > 0
+		s.drops--
+		s.mu.Unlock()
+
+		if !supportRange {
+			r.Header.Del("Range")
+		}
+		if drop {
+			w = &droppingWriter{ResponseWriter: w, limit: len(archive) / (drops + 2)}
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Builds the archive of a model holding a large, incompressible, file.
+func buildModelArchive(t *testing.T, model string) ([]byte, []byte) {
+	t.Helper()
+	content := make([]byte, 256*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+	archive := buildArchive(t, []tar.Header{
+		{Name: model + "/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: model + "/model_optimized.onnx", Typeflag: tar.TypeReg, Mode: 0644},
+	}, map[string]string{
+		model + "/model_optimized.onnx": string(content),
+	})
+	return archive, content
+}
+
+func TestDownloadModelResumes(t *testing.T) {
+	for _, supportRange := range []bool{true, false} {
+		t.Run(fmt.Sprintf("range support %t", supportRange), func(t *testing.T) {
+			archive, content := buildModelArchive(t, "test-model")
+			server := newFlakyServer(t, archive, 2, supportRange)
+
+			descriptor := testModelDescriptor
+			descriptor.URL = server.URL + "/test-model.tar.gz"
+			descriptor.ArchiveSHA256 = sha256Hex(archive)
+
+			cacheDir := t.TempDir()
+			modelPath, err := fastembed.DownloadModel(descriptor, cacheDir, server.Client(), 2, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertCacheEntries(t, cacheDir, "test-model")
+
+			extracted, err := os.ReadFile(filepath.Join(modelPath, "model_optimized.onnx"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(extracted, content) {
+				t.Error("Expected the extracted file to match the archived one")
+			}
+
+			if len(server.ranges) != 3 {
+				t.Fatalf("Expected 3 requests, got %d", len(server.ranges))
+			}
+			if server.ranges[0] != "" {
+				t.Errorf("Expected the first request to have no range, got %q", server.ranges[0])
+			}
+			for _, r := range server.ranges[1:] {
+				if !strings.HasPrefix(r, "bytes=") || r == "bytes=0-" {
+					t.Errorf("Expected the retries to resume the download, got range %q", r)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadModelResumesPartialFile(t *testing.T) {
+	archive, _ := buildModelArchive(t, "test-model")
+	server := newFlakyServer(t, archive, 0, true)
+
+	// A previous download stopped half way.
+	cacheDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cacheDir, ".test-model.tar.gz.part"), archive[:len(archive)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	descriptor := testModelDescriptor
+	descriptor.URL = server.URL + "/test-model.tar.gz"
+	descriptor.ArchiveSHA256 = sha256Hex(archive)
+	if _, err := fastembed.DownloadModel(descriptor, cacheDir, server.Client(), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheEntries(t, cacheDir, "test-model")
+
+	if expected := fmt.Sprintf("bytes=%d-", len(archive)/2); len(server.ranges) != 1 || server.ranges[0] != expected {
+		t.Errorf("Expected a single request for range %q, got %v", expected, server.ranges)
+	}
+}
+
+func TestDownloadModelRetries(t *testing.T) {
+	testCases := []struct {
+		status   int
+		requests int
+	}{
+		{status: http.StatusServiceUnavailable, requests: 3},
+		{status: http.StatusTooManyRequests, requests: 3},
+		{status: http.StatusNotFound, requests: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			descriptor := testModelDescriptor
+			descriptor.URL = server.URL + "/test-model.tar.gz"
+			if _, err := fastembed.DownloadModel(descriptor, t.TempDir(), server.Client(), 2, nil); err == nil {
+				t.Fatal("Expected an error")
+			}
+			if int(requests.Load()) != tc.requests {
+				t.Errorf("Expected %d requests, got %d", tc.requests, requests.Load())
+			}
+		})
+	}
+}
+
+func TestRetrieveModelBaseURL(t *testing.T) {
+	testCases := []struct {
+		model fastembed.EmbeddingModel
+		path  string
+	}{
+		{model: fastembed.BGESmallENV15, path: "/mirror/fast-bge-small-en-v1.5.tar.gz"},
+		// The archive of the multilingual model is named after its Hugging Face owner, its directory is not.
+		{model: fastembed.MLE5Large, path: "/mirror/intfloat-multilingual-e5-large.tar.gz"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.model), func(t *testing.T) {
+			archive, _ := buildModelArchive(t, string(tc.model))
+			var path string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+			}))
+			defer server.Close()
+
+			showDownloadProgress := false
+			cacheDir := t.TempDir()
+			_, err := fastembed.RetrieveModel(tc.model, &fastembed.InitOptions{
+				CacheDir:             cacheDir,
+				ShowDownloadProgress: &showDownloadProgress,
+				HTTPClient:           server.Client(),
+				BaseURL:              server.URL + "/mirror/",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != tc.path {
+				t.Errorf("Expected a request for %s, got %s", tc.path, path)
+			}
+			assertCacheEntries(t, cacheDir, string(tc.model))
+		})
+	}
+}
+
+func TestRetrieveModelRecordsLastUse(t *testing.T) {
+	cacheDir := t.TempDir()
+	modelPath := filepath.Join(cacheDir, string(fastembed.BGESmallENV15))
+	if err := os.MkdirAll(modelPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	if err := os.Chtimes(modelPath, lastWeek, lastWeek); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fastembed.RetrieveModel(fastembed.BGESmallENV15, &fastembed.InitOptions{CacheDir: cacheDir}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(modelPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().After(lastWeek) {
+		t.Errorf("Expected the last use time to be updated, got %v", info.ModTime())
+	}
+}
+
+func TestDefaultHTTPClient(t *testing.T) {
+	client := fastembed.WithDefaultOptions(nil).HTTPClient
+	if client == http.DefaultClient {
+		t.Fatal("Expected a client with timeouts, got http.DefaultClient")
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected an *http.Transport, got %T", client.Transport)
+	}
+	// A server accepting the connection but never answering fails the attempt instead of hanging.
+	if transport.DialContext == nil || transport.ResponseHeaderTimeout <= 0 {
+		t.Errorf("Expected connection and response header timeouts, got %v", transport.ResponseHeaderTimeout)
+	}
+	if transport.Proxy == nil {
+		t.Error("Expected the proxy of the environment to be used")
+	}
+
+	custom := &http.Client{}
+	if fastembed.WithDefaultOptions(&fastembed.InitOptions{HTTPClient: custom}).HTTPClient != custom {
+		t.Error("Expected the client of the options to be kept")
+	}
+}
+
+func TestDownloadRetriesOption(t *testing.T) {
+	if retries := fastembed.WithDefaultOptions(nil).DownloadRetries; retries == nil || *retries != 5 {
+		t.Errorf("Expected 5 retries by default, got %v", retries)
+	}
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// 0 disables the retries.
+	downloadRetries := 0
+	showDownloadProgress := false
+	_, err := fastembed.RetrieveModel(fastembed.BGESmallENV15, &fastembed.InitOptions{
+		CacheDir:             t.TempDir(),
+		ShowDownloadProgress: &showDownloadProgress,
+		HTTPClient:           server.Client(),
+		BaseURL:              server.URL,
+		DownloadRetries:      &downloadRetries,
+	})
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected a single request, got %d", requests.Load())
+	}
+}
@@ -0,0 +1,57 @@
+package fastembed
+
+import (
+	"os"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// The onnxruntime environment is process-wide and shared by every model.
+// It is initialized by the first model and destroyed when the last model is closed.
+var (
+	environmentMu    sync.Mutex
+	environmentRefs  int
+	environmentOwned bool
+)
+
+// Private function to take a reference on the onnxruntime environment, initializing it if needed.
+// Every successful call must be paired with a call to releaseEnvironment.
+func acquireEnvironment() error {
+	environmentMu.Lock()
+	defer environmentMu.Unlock()
+
+	if environmentRefs == 0 {
+		if ort.IsInitialized() {
+			// Initialized outside of this package, leave its lifetime to the caller.
+			environmentOwned = false
+		} else {
+			if onnxPath := os.Getenv("ONNX_PATH"); onnxPath != "" {
+				ort.SetSharedLibraryPath(onnxPath)
+			}
+			if err := ort.InitializeEnvironment(); err != nil {
+				return err
+			}
+			environmentOwned = true
+		}
+	}
+	environmentRefs++
+	return nil
+}
+
+// Private function to drop a reference on the onnxruntime environment.
+// The environment is destroyed once the last reference is released.
+func releaseEnvironment() error {
+	environmentMu.Lock()
+	defer environmentMu.Unlock()
+
+	if environmentRefs == 0 {
+		return nil
+	}
+	environmentRefs--
+	if environmentRefs > 0 || !environmentOwned {
+		return nil
+	}
+	environmentOwned = false
+	return ort.DestroyEnvironment()
+}
@@ -0,0 +1,167 @@
+package fastembed
+
+import (
+	"context"
+	"image"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/anush008/fastembed-go/sentencepiece"
+	"github.com/sugarme/tokenizer"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Exposes private functions to the fastembed_test package.
+var (
+	Pool                = pool
+	Normalize           = normalize
+	GetSparseEmbeddings = getSparseEmbeddings
+	GetTokenEmbeddings  = getTokenEmbeddings
+	TokenBatches        = tokenBatches
+	RankDocuments       = rankDocuments
+	ChunkWindows        = chunkWindows
+	AggregateChunks     = aggregateChunks
+	GetModelDescriptor  = getModelDescriptor
+	ModelLockPath       = modelLockPath
+	RemoveStaleLock     = removeStaleLock
+	WithDefaultOptions  = withDefaultOptions
+)
+
+// Lists the names of the models shipped with the package, fetched from GCS or the Hugging Face Hub.
+func BuiltinModelNames() []EmbeddingModel {
+	var names []EmbeddingModel
+	for _, info := range builtinModels() {
+		names = append(names, info.Model)
+	}
+	for _, descriptor := range builtinHubModels() {
+		names = append(names, descriptor.Model)
+	}
+	return names
+}
+
+// Extracts a model archive into the cache directory, without reporting the progress.
+func ExtractModel(tarball io.Reader, descriptor ModelDescriptor, cacheDir string) (string, error) {
+	return extractModel(tarball, -1, descriptor, cacheDir, nil)
+}
+
+// Preprocesses an image as described by the preprocessor_config.json file at configPath.
+func PreprocessImage(configPath string, img image.Image) ([]float32, error) {
+	config, err := loadPreprocessorConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return config.preprocess(img)
+}
+
+// Retrieves a model from the cache, or downloads it, as done when initializing a model.
+func RetrieveModel(model EmbeddingModel, options *InitOptions) (string, error) {
+	options = withDefaultOptions(options)
+	descriptor, err := getModelDescriptor(model)
+	if err != nil {
+		return "", err
+	}
+	return retrieveModel(descriptor, options)
+}
+
+// Downloads a model with the given number of retries, waiting a millisecond before the first one.
+func DownloadModel(descriptor ModelDescriptor, cacheDir string, client *http.Client, retries int, progress ProgressFunc) (string, error) {
+	d := &downloader{client: client, retries: retries, backoff: time.Millisecond, progress: progress}
+	return d.downloadModel(descriptor, cacheDir)
+}
+
+// Takes the lock file at path, returning the function releasing it.
+func AcquireLock(path string, timeout time.Duration) (func() error, error) {
+	lock, err := acquireLock(path, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return lock.release, nil
+}
+
+// Matches a descriptor against the inputs and outputs of an ONNX model, as done when loading a dense text model.
+// Returns the descriptor with its input and output names set, and whether the output is pooled.
+func ResolveDenseGraph(descriptor ModelDescriptor, customPooling bool, inputs, outputs []ort.InputOutputInfo) (ModelDescriptor, bool, error) {
+	descriptor, output, err := resolveGraph(descriptor, denseGraphSpec(customPooling), inputs, outputs)
+	if err != nil {
+		return descriptor, false, err
+	}
+	return descriptor, (&onnxModel{output: output}).pooled(), nil
+}
+
+// Matches a descriptor against the ONNX file at path, as done when loading a dense text model.
+// Returns the descriptor with its input and output names set, and whether the output is pooled.
+func InspectDenseGraph(path string, descriptor ModelDescriptor, customPooling bool) (ModelDescriptor, bool, error) {
+	descriptor, output, err := inspectGraph(path, descriptor, denseGraphSpec(customPooling))
+	if err != nil {
+		return descriptor, false, err
+	}
+	return descriptor, (&onnxModel{output: output}).pooled(), nil
+}
+
+// Loads a FlagEmbedding made of the tokenizer.json file at tokenizerPath only, truncating the inputs to maxLength tokens.
+// It tokenizes inputs but cannot embed them, and has no session to close: the returned function stops its workers.
+func NewTokenizerOnlyEmbedding(tokenizerPath string, maxLength int) (*FlagEmbedding, func(), error) {
+	tk, err := sentencepiece.FromFile(tokenizerPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	tk.WithTruncation(&tokenizer.TruncationParams{MaxLength: maxLength, Strategy: tokenizer.LongestFirst})
+	padding := tokenizer.PaddingParams{Strategy: *tokenizer.NewPaddingStrategy(), Direction: tokenizer.Right}
+	tk.WithPadding(&padding)
+	f := &FlagEmbedding{onnxModel: &onnxModel{tokenizer: tk, pool: newWorkerPool(1)}}
+	return f, f.pool.stop, nil
+}
+
+// Loads the tokenizer of the model in modelPath as done when loading a model, returning the length the inputs are truncated to.
+func TruncationLength(modelPath string, descriptor ModelDescriptor, maxLength, reservedTokens int) (int, error) {
+	tk, err := loadTokenizer(modelPath, descriptor.withDefaults(), maxLength, reservedTokens)
+	if err != nil {
+		return 0, err
+	}
+	return tk.GetTruncation().MaxLength, nil
+}
+
+// Tokenizes a batch of input strings as Embed does, returning the padded ids of each input and their metadata.
+func EncodeBatch(f *FlagEmbedding, input []string) ([][]int64, []InputMetadata, error) {
+	batch, err := f.encode(input)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([][]int64, batch.size)
+	for i := range ids {
+		ids[i] = batch.inputIds[i*batch.seqLen : (i+1)*batch.seqLen]
+	}
+	return ids, batch.metadata, nil
+}
+
+// Tokenizes (query, document) pairs as Rerank does, returning the padded ids and token type ids of each pair.
+func EncodePairs(f *FlagEmbedding, query string, documents []string) ([][]int64, [][]int64, error) {
+	batch, err := f.encodePairs(query, documents)
+	if err != nil {
+		return nil, nil, err
+	}
+	ids := make([][]int64, batch.size)
+	typeIDs := make([][]int64, batch.size)
+	for i := range ids {
+		ids[i] = batch.inputIds[i*batch.seqLen : (i+1)*batch.seqLen]
+		typeIDs[i] = batch.inputTypeIds[i*batch.seqLen : (i+1)*batch.seqLen]
+	}
+	return ids, typeIDs, nil
+}
+
+// Splits documents into chunks of chunkLength tokens, as done by EmbedLong.
+// Returns the chunks of each document, without their embedding, and the token ids of each chunk.
+func ChunkDocuments(f *FlagEmbedding, input []string, chunkLength, stride int) ([][]ChunkEmbedding, [][][]int, error) {
+	chunks, err := f.chunkDocuments(context.Background(), input, chunkLength, stride, 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	spans := make([][]ChunkEmbedding, len(input))
+	ids := make([][][]int, len(input))
+	for _, chunk := range chunks {
+		spans[chunk.document] = append(spans[chunk.document], chunk.span)
+		ids[chunk.document] = append(ids[chunk.document], chunk.encoding.GetIds())
+	}
+	return spans, ids, nil
+}
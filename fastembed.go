@@ -1,22 +1,20 @@
 package fastembed
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"io/fs"
 	"math"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
+	"runtime"
+	"strconv"
+	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/anush008/fastembed-go/sentencepiece"
 	"github.com/sugarme/tokenizer"
-	"github.com/sugarme/tokenizer/pretrained"
 	ort "github.com/yalue/onnxruntime_go"
 )
 
@@ -30,38 +28,80 @@ const (
 	BGESmallEN    EmbeddingModel = "fast-bge-small-en"
 	BGESmallENV15 EmbeddingModel = "fast-bge-small-en-v1.5"
 	BGESmallZH    EmbeddingModel = "fast-bge-small-zh-v1.5"
-
-// A model with type "Unigram" is not yet supported by the tokenizer
-// Ref: https://github.com/sugarme/tokenizer/blob/448e79b1ed65947b8c6343bf9aa39e78364f45c8/pretrained/model.go#L152
-// MLE5Large     EmbeddingModel = "fast-multilingual-e5-large"
+	MLE5Large     EmbeddingModel = "fast-multilingual-e5-large"
 )
 
+// The environment variable overriding the default cache directory.
+const cacheDirEnv = "FASTEMBED_CACHE_PATH"
+
+// Error returned when embedding with a model that has been closed.
+var ErrModelClosed = errors.New("model is closed")
+
 // Struct to interface with a FastEmbed model.
 type FlagEmbedding struct {
-	tokenizer *tokenizer.Tokenizer
-	model     EmbeddingModel
-	maxLength int
-	modelPath string
+	*onnxModel
+	pooling        PoolingStrategy
+	normalize      bool
+	maxBatchTokens int
 }
 
 // Options to initialize a FastEmbed model
 // Model: The model to use for embedding
-// ExecutionProviders: The execution providers to use for onnxruntime
+// ExecutionProviders: The names of the execution providers to use for onnxruntime, used when SessionConfig lists none
+// SessionConfig: The onnxruntime session options
 // MaxLength: The maximum length of the input sequence
-// CacheDir: The directory to cache the model files
-// ShowDownloadProgress: Whether to show the download progress bar
+// CacheDir: The directory to cache the model files, defaults to DefaultCacheDir()
+// ShowDownloadProgress: Whether to draw the progress bars of the model retrieval to the standard error, when ProgressFunc is not set
+// MaxConcurrentBatches: The maximum number of batches embedded at the same time, defaults to GOMAXPROCS
+// Pooling: The strategy to pool the token embeddings, defaults to the one the model was trained with
+// Normalize: Whether to L2 normalize the embeddings, defaults to true
+// HTTPClient: The client used to download the models, defaults to a client waiting at most 30 seconds for a connection
+// and a minute for the response headers
+// BaseURL: The URL the built-in models are downloaded from, defaults to "https://storage.googleapis.com/qdrant-fastembed"
+// DownloadRetries: The number of times a failed download is retried, with an exponential backoff, defaults to 5,
+// 0 disabling the retries
+// Source: Where the model is fetched from, defaults to GCSSource
+// Offline: Whether to fail instead of downloading a model missing from the cache,
+// also enabled by setting the FASTEMBED_OFFLINE environment variable to "1" or "true"
+// LockTimeout: How long to wait for another process downloading the same model, defaults to 30 minutes
+// ProgressFunc: The function receiving the progress of the download, extraction and verification of the model,
+// defaults to ProgressBar() if ShowDownloadProgress is true, nothing is reported otherwise
+// MaxBatchTokens: The maximum number of tokens of a batch, padding included, so its size times its sequence length.
+// When set, Embed sorts the inputs by token length and groups them into batches of similar lengths,
+// bounded by MaxBatchTokens and the batch size, none by default
 // NOTE:
 // We use a pointer for "ShowDownloadProgress" so that we can distinguish between the user
 // not setting this flag and the user setting it to false. We want the default value to be true.
 // As Go assigns a default(empty) value of "false" to bools, we can't distinguish
 // if the user set it to false or not set at all.
 // A pointer to bool will be nil if not set explicitly.
+// The same applies to "Normalize", and to "DownloadRetries" for which 0 is a valid value.
 type InitOptions struct {
 	Model                EmbeddingModel
 	ExecutionProviders   []string
+	SessionConfig        *SessionConfig
 	MaxLength            int
 	CacheDir             string
 	ShowDownloadProgress *bool
+	MaxConcurrentBatches int
+	Pooling              PoolingStrategy
+	Normalize            *bool
+	HTTPClient           *http.Client
+	BaseURL              string
+	DownloadRetries      *int
+	Source               ModelSource
+	Offline              bool
+	LockTimeout          time.Duration
+	ProgressFunc         ProgressFunc
+	MaxBatchTokens       int
+}
+
+// Options of a single call to EmbedWithOptions
+// BatchSize: The number of inputs to embed in a single batch, defaults to 256
+// Normalize: Whether to L2 normalize the embeddings, defaults to InitOptions.Normalize
+type EmbedOptions struct {
+	BatchSize int
+	Normalize *bool
 }
 
 // Struct to represent FastEmbed model information.
@@ -69,16 +109,66 @@ type ModelInfo struct {
 	Model       EmbeddingModel
 	Dim         int
 	Description string
+	Pooling     PoolingStrategy
 }
 
 // Function to initialize a FastEmbed model.
 func NewFlagEmbedding(options *InitOptions) (*FlagEmbedding, error) {
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	descriptor, err := getModelDescriptor(options.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	modelPath, err := retrieveModel(descriptor, options)
+	if err != nil {
+		return nil, err
+	}
+	return loadFlagEmbedding(modelPath, descriptor, options)
+}
+
+// Function to initialize a model from a local directory holding the ONNX and tokenizer files.
+// The descriptor does not need to be registered, options.Model and options.CacheDir are ignored.
+func NewFlagEmbeddingFromDir(dir string, descriptor ModelDescriptor, options *InitOptions) (*FlagEmbedding, error) {
+	descriptor = descriptor.withDefaults()
+	if err := descriptor.validate(); err != nil {
+		return nil, err
+	}
+
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	options.Model = descriptor.Model
+	return loadFlagEmbedding(dir, descriptor, options)
+}
+
+// Function to return the default cache directory
+// The FASTEMBED_CACHE_PATH environment variable if set, else a "fastembed" directory of the user cache directory,
+// and "local_cache" if the user cache directory is unknown.
+func DefaultCacheDir() string {
+	if cacheDir := os.Getenv(cacheDirEnv); cacheDir != "" {
+		return cacheDir
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "local_cache"
+	}
+	return filepath.Join(userCacheDir, "fastembed")
+}
+
+// Private function to set the defaults of the unset options.
+func withDefaultOptions(options *InitOptions) *InitOptions {
 	if options == nil {
 		options = &InitOptions{}
 	}
 
 	if options.CacheDir == "" {
-		options.CacheDir = "local_cache"
+		options.CacheDir = DefaultCacheDir()
 	}
 
 	if options.Model == "" {
@@ -94,229 +184,302 @@ func NewFlagEmbedding(options *InitOptions) (*FlagEmbedding, error) {
 		options.ShowDownloadProgress = &showDownloadProgress
 	}
 
-	if onnxPath := os.Getenv("ONNX_PATH"); onnxPath != "" {
-		ort.SetSharedLibraryPath(onnxPath)
+	if options.ProgressFunc == nil && *options.ShowDownloadProgress {
+		options.ProgressFunc = ProgressBar()
 	}
 
-	if !ort.IsInitialized() {
-		err := ort.InitializeEnvironment()
-		if err != nil {
-			return nil, err
-		}
+	if options.Normalize == nil {
+		normalizeEmbeddings := true
+		options.Normalize = &normalizeEmbeddings
 	}
 
-	modelPath, err := retrieveModel(options.Model, options.CacheDir, *options.ShowDownloadProgress)
-	if err != nil {
-		return nil, err
+	if options.MaxConcurrentBatches <= 0 {
+		options.MaxConcurrentBatches = runtime.GOMAXPROCS(0)
 	}
 
-	tknzer, err := loadTokenizer(modelPath, options.MaxLength)
-	if err != nil {
-		return nil, err
+	if options.HTTPClient == nil {
+		options.HTTPClient = defaultHTTPClient
 	}
-	return &FlagEmbedding{
-		tokenizer: tknzer,
-		model:     options.Model,
-		maxLength: options.MaxLength,
-		modelPath: modelPath,
-	}, nil
-}
-
-// Function to cleanup the internal onnxruntime environment when it is no longer needed.
-func (f *FlagEmbedding) Destroy() error {
-	return ort.DestroyEnvironment()
-}
 
-// Private function to embed a batch of input strings.
-func (f *FlagEmbedding) onnxEmbed(input []string) ([]([]float32), error) {
-	inputs := make([]tokenizer.EncodeInput, len(input))
-	for index, v := range input {
-		sequence := tokenizer.NewInputSequence(v)
-		inputs[index] = tokenizer.NewSingleEncodeInput(sequence)
+	if options.BaseURL == "" {
+		options.BaseURL = defaultBaseURL
 	}
 
-	encodings, err := f.tokenizer.EncodeBatch(inputs, true)
-	if err != nil {
-		return nil, err
+	if options.DownloadRetries == nil {
+		downloadRetries := 5
+		options.DownloadRetries = &downloadRetries
 	}
 
-	inputIdsFlat, inputMaskFlat, inputTypeIdsFlat := make([]int64, 0), make([]int64, 0), make([]int64, 0)
-	for _, encoding := range encodings {
-		inputIds, inputMask, inputTypeIds := encodingToInt32(encoding.GetIds(), encoding.GetAttentionMask(), encoding.GetTypeIds())
-		inputIdsFlat = append(inputIdsFlat, inputIds...)
-		inputMaskFlat = append(inputMaskFlat, inputMask...)
-		inputTypeIdsFlat = append(inputTypeIdsFlat, inputTypeIds...)
+	if !options.Offline {
+		options.Offline, _ = strconv.ParseBool(os.Getenv(offlineEnv))
 	}
 
-	inputShape := ort.NewShape(int64(len(inputs)), int64(encodings[0].Len()))
-
-	inputTensorID, err := ort.NewTensor(inputShape, inputIdsFlat)
-	if err != nil {
-		return nil, err
+	if options.LockTimeout <= 0 {
+		options.LockTimeout = 30 * time.Minute
 	}
-	defer inputTensorID.Destroy()
-
-	inputTensorMask, err := ort.NewTensor(inputShape, inputMaskFlat)
+	return options
+}
 
-	if err != nil {
-		return nil, err
+// Private function to merge SessionConfig with the ExecutionProviders option.
+func (options *InitOptions) sessionConfig() SessionConfig {
+	sessionConfig := SessionConfig{}
+	if options.SessionConfig != nil {
+		sessionConfig = *options.SessionConfig
 	}
-	defer inputTensorMask.Destroy()
+	if len(sessionConfig.ExecutionProviders) == 0 {
+		sessionConfig.ExecutionProviders = toExecutionProviders(options.ExecutionProviders)
+	}
+	return sessionConfig
+}
 
-	inputTensorType, err := ort.NewTensor(inputShape, inputTypeIdsFlat)
+// Private function to check the options that do not depend on the model,
+// so an invalid configuration fails before the model is downloaded.
+func validateOptions(options *InitOptions) error {
+	sessionConfig := options.sessionConfig()
+	return sessionConfig.validate()
+}
 
-	if err != nil {
+// Private function to load the model files and check the FlagEmbedding specific options.
+func loadFlagEmbedding(modelPath string, descriptor ModelDescriptor, options *InitOptions) (*FlagEmbedding, error) {
+	pooling := options.Pooling
+	if pooling == "" {
+		pooling = descriptor.Pooling
+	}
+	if pooling == "" {
+		return nil, fmt.Errorf("model %s has no pooling strategy, set one in InitOptions.Pooling", descriptor.Model)
+	}
+	if err := validatePooling(pooling); err != nil {
 		return nil, err
 	}
-	defer inputTensorType.Destroy()
 
-	modelInfo, err := getModelInfo(f.model)
+	model, err := loadONNXModel(modelPath, descriptor, options, denseGraphSpec(options.Pooling != ""), 0)
 	if err != nil {
 		return nil, err
 	}
 
-	outputShape := ort.NewShape(int64(len(inputs)), int64(int64(encodings[0].Len())), int64(modelInfo.Dim))
-	outputTensor, err := ort.NewEmptyTensor[float32](outputShape)
-	if err != nil {
-		return nil, err
+	return &FlagEmbedding{
+		onnxModel:      model,
+		pooling:        pooling,
+		normalize:      *options.Normalize,
+		maxBatchTokens: options.MaxBatchTokens,
+	}, nil
+}
+
+// Private function to return the spec of the dense text models, preferring the pooled output of the model
+// unless the pooling strategy is set in the options, in which case the token embeddings are pooled with it.
+func denseGraphSpec(customPooling bool) graphSpec {
+	if customPooling {
+		return textGraphSpec([]int{2, 3}, "last_hidden_state", "token_embeddings")
 	}
-	defer outputTensor.Destroy()
+	return textGraphSpec([]int{2, 3}, "sentence_embedding", "last_hidden_state", "token_embeddings")
+}
+
+// Function to release the model session when it is no longer needed.
+// The onnxruntime environment is shared by all models and destroyed when the last one is closed.
+// Calling Close more than once is a no-op.
+func (f *FlagEmbedding) Close() error {
+	return f.close()
+}
 
-	// Skip token_type_ids for intfloat-multilingual-e5-large when available
-	session, err := ort.NewAdvancedSession(filepath.Join(f.modelPath, "model_optimized.onnx"), []string{
-		"input_ids", "attention_mask", "token_type_ids",
-	}, []string{
-		"last_hidden_state",
-	}, []ort.ArbitraryTensor{
-		inputTensorID, inputTensorMask, inputTensorType,
-	}, []ort.ArbitraryTensor{outputTensor},
-		nil)
+// Function to cleanup the model when it is no longer needed.
+// Equivalent to Close.
+func (f *FlagEmbedding) Destroy() error {
+	return f.Close()
+}
 
+// Private function to embed a batch of input strings, returning the metadata of the inputs.
+func (f *FlagEmbedding) onnxEmbed(input []string, normalized bool) ([]([]float32), []InputMetadata, error) {
+	batch, err := f.encode(input)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	embeddings, err := f.embedBatch(batch, normalized)
+	if err != nil {
+		return nil, nil, err
 	}
+	return embeddings, batch.metadata, nil
+}
 
-	defer session.Destroy()
+// Private function to embed a tokenized batch, L2 normalizing the embeddings if normalized is true.
+func (f *FlagEmbedding) embedBatch(batch *encodedBatch, normalized bool) ([]([]float32), error) {
+	if f.pooled() {
+		data, err := f.run(batch, ort.NewShape(int64(batch.size), int64(f.descriptor.Dim)))
+		if err != nil {
+			return nil, err
+		}
+		return getPooledEmbeddings(data, f.descriptor.Dim, normalized), nil
+	}
 
-	err = session.Run()
+	shape := batch.tokenShape(f.descriptor.Dim)
+	data, err := f.run(batch, shape)
 	if err != nil {
 		return nil, err
 	}
 
-	return getEmbeddings(outputTensor.GetData(), outputTensor.GetShape()), nil
+	return getEmbeddings(data, shape, batch.inputMask, f.pooling, normalized), nil
 }
 
 // Function to embed a batch of input strings
 // The batchSize parameter controls the number of inputs to embed in a single batch
-// The batches are processed in parallel
+// The batches are processed in parallel, by at most InitOptions.MaxConcurrentBatches workers
 // Returns the first error encountered if any
 // Default batch size is 256.
 func (f *FlagEmbedding) Embed(input []string, batchSize int) ([]([]float32), error) {
+	return f.EmbedContext(context.Background(), input, batchSize)
+}
+
+// Function to embed a batch of input strings, stopping early when the context is done
+// No new batches are started once the context is cancelled or its deadline is exceeded,
+// batches already running are completed and the context error is returned.
+// A failing batch also stops the remaining ones.
+// All the batches have returned by the time this function returns.
+// With InitOptions.MaxBatchTokens, the batches are bounded by their number of tokens too, see embedByTokens.
+func (f *FlagEmbedding) EmbedContext(ctx context.Context, input []string, batchSize int) ([]([]float32), error) {
+	embeddings, _, err := f.embed(ctx, input, batchSize, f.normalize)
+	return embeddings, err
+}
+
+// Function to embed a batch of input strings as Embed does, with the options of this call.
+func (f *FlagEmbedding) EmbedWithOptions(input []string, options *EmbedOptions) ([]([]float32), error) {
+	return f.EmbedWithOptionsContext(context.Background(), input, options)
+}
+
+// Function to embed a batch of input strings as EmbedContext does, with the options of this call.
+func (f *FlagEmbedding) EmbedWithOptionsContext(ctx context.Context, input []string, options *EmbedOptions) ([]([]float32), error) {
+	if options == nil {
+		options = &EmbedOptions{}
+	}
+	normalized := f.normalize
+	if options.Normalize != nil {
+		normalized = *options.Normalize
+	}
+	embeddings, _, err := f.embed(ctx, input, options.BatchSize, normalized)
+	return embeddings, err
+}
+
+// Private function to embed a batch of input strings, returning the metadata of the inputs.
+// The embeddings are L2 normalized if normalized is true.
+func (f *FlagEmbedding) embed(ctx context.Context, input []string, batchSize int, normalized bool) ([]([]float32), []InputMetadata, error) {
 	if batchSize <= 0 {
 		batchSize = 256
 	}
-	embeddings := make([]([]float32), len(input))
-	var wg sync.WaitGroup
-	errorCh := make(chan error, len(input))
-	// var resultsMutex sync.Mutex
-
-	for i := 0; i < len(input); i += batchSize {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			end := i + batchSize
-			if end > len(input) {
-				end = len(input)
-			}
-			batchOut, err := f.onnxEmbed(input[i:end])
-			if err != nil {
-				errorCh <- err
-			}
-			// resultsMutex.Lock()
-			// defer resultsMutex.Unlock()
-			// Removed the mutex as the slice positions being accessed are unique for each goroutine and there is no overlap
-			copy(embeddings[i:end], batchOut)
-		}(i)
+	if f.maxBatchTokens > 0 {
+		return f.embedByTokens(ctx, input, batchSize, normalized)
 	}
-	wg.Wait()
-	close(errorCh)
-
-	// We can aggregate the errors if we ever need to
-	if len(errorCh) > 0 {
-		return nil, <-errorCh
+	embeddings := make([]([]float32), len(input))
+	metadata := make([]InputMetadata, len(input))
+	err := f.runBatches(ctx, len(input), batchSize, func(start, end int) error {
+		batchOut, batchMetadata, err := f.onnxEmbed(input[start:end], normalized)
+		if err != nil {
+			return err
+		}
+		// The slice positions being accessed are unique for each batch and there is no overlap
+		copy(embeddings[start:end], batchOut)
+		copy(metadata[start:end], batchMetadata)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
-	return embeddings, nil
+	return embeddings, metadata, nil
 }
 
-// Function to embed a single input string prefixed with "query: "
+// Function to embed a single input string prefixed with "query: ", or the query prefix of the model
 // Recommended for generating query embeddings for semantic search.
 func (f *FlagEmbedding) QueryEmbed(input string) ([]float32, error) {
-	query := "query: " + input
-	data, err := f.onnxEmbed([]string{query})
+	return f.QueryEmbedContext(context.Background(), input)
+}
+
+// Function to embed a single input string prefixed with the query prefix of the model, unless the context is already done.
+func (f *FlagEmbedding) QueryEmbedContext(ctx context.Context, input string) ([]float32, error) {
+	query := f.descriptor.QueryPrefix + input
+	data, err := f.EmbedContext(ctx, []string{query}, 1)
 	if err != nil {
 		return nil, err
 	}
 	return data[0], nil
 }
 
-// Function to embed string prefixed with "passage: ".
+// Function to embed string prefixed with "passage: ", or the passage prefix of the model.
 func (f *FlagEmbedding) PassageEmbed(input []string, batchSize int) ([]([]float32), error) {
+	return f.PassageEmbedContext(context.Background(), input, batchSize)
+}
+
+// Function to embed string prefixed with the passage prefix of the model, stopping early when the context is done.
+// See EmbedContext for the cancellation semantics.
+func (f *FlagEmbedding) PassageEmbedContext(ctx context.Context, input []string, batchSize int) ([]([]float32), error) {
 	processedInput := make([]string, len(input))
 	for i, v := range input {
-		processedInput[i] = "passage: " + v
+		processedInput[i] = f.descriptor.PassagePrefix + v
 	}
-	return f.Embed(processedInput, batchSize)
+	return f.EmbedContext(ctx, processedInput, batchSize)
 }
 
-// Function to list the supported FastEmbed models.
+// Function to list the supported FastEmbed models, including the ones added with RegisterModel.
 func ListSupportedModels() []ModelInfo {
+	return append(builtinModels(), registeredModelInfos()...)
+}
+
+// Private function to list the FastEmbed models shipped with the package.
+func builtinModels() []ModelInfo {
 	return []ModelInfo{
 		{
 			Model:       AllMiniLML6V2,
 			Dim:         384,
 			Description: "Sentence Transformer model, MiniLM-L6-v2",
+			Pooling:     MeanPooling,
 		},
 		{
 			Model:       BGEBaseEN,
 			Dim:         768,
 			Description: "Base English model",
+			Pooling:     CLSPooling,
 		},
 		{
 			Model:       BGEBaseENV15,
 			Dim:         768,
 			Description: "v1.5 release of the base English model",
+			Pooling:     CLSPooling,
 		},
 		{
 			Model:       BGESmallEN,
 			Dim:         384,
 			Description: "Fast English model",
+			Pooling:     CLSPooling,
 		},
 		{
 			Model:       BGESmallENV15,
 			Dim:         384,
 			Description: "Fast, default English model",
+			Pooling:     CLSPooling,
 		},
 		{
 			Model:       BGESmallZH,
 			Dim:         512,
 			Description: "Fast Chinese model",
+			Pooling:     CLSPooling,
+		},
+		{
+			Model:       MLE5Large,
+			Dim:         1024,
+			Description: "Multilingual model, e5-large. Recommend using this model for non-English languages",
+			Pooling:     MeanPooling,
 		},
-		// {
-		// 	Model:       MLE5Large,
-		// 	Dim:         1024,
-		// 	Description: "Multilingual model, e5-large. Recommend using this model for non-English languages",
-		// },
 	}
 }
 
-func loadTokenizer(modelPath string, maxLength int) (*tokenizer.Tokenizer, error) {
-	tknzer, err := pretrained.FromFile(filepath.Join(modelPath, "tokenizer.json"))
+// Private function to load the tokenizer of a model, truncating the inputs to maxLength tokens
+// maxLength is capped by the model_max_length of the tokenizer configuration,
+// then lowered by reservedTokens, the tokens added to the inputs after tokenization.
+func loadTokenizer(modelPath string, descriptor ModelDescriptor, maxLength, reservedTokens int) (*tokenizer.Tokenizer, error) {
+	// The sentencepiece package also loads the Unigram tokenizers of the multilingual models, that the pretrained package cannot load.
+	tknzer, err := sentencepiece.FromFile(filepath.Join(modelPath, descriptor.TokenizerFile))
 
 	if err != nil {
 		return nil, err
 	}
 
-	configData, err := os.ReadFile(filepath.Join(modelPath, "config.json"))
+	configData, err := os.ReadFile(filepath.Join(modelPath, descriptor.ConfigFile))
 	if err != nil {
 		return nil, err
 	}
@@ -328,7 +491,7 @@ func loadTokenizer(modelPath string, maxLength int) (*tokenizer.Tokenizer, error
 		return nil, err
 	}
 
-	tokenizerConfigData, err := os.ReadFile(filepath.Join(modelPath, "tokenizer_config.json"))
+	tokenizerConfigData, err := os.ReadFile(filepath.Join(modelPath, descriptor.TokenizerConfigFile))
 	if err != nil {
 		return nil, err
 	}
@@ -340,7 +503,7 @@ func loadTokenizer(modelPath string, maxLength int) (*tokenizer.Tokenizer, error
 		return nil, err
 	}
 
-	tokensMapData, err := os.ReadFile(filepath.Join(modelPath, "special_tokens_map.json"))
+	tokensMapData, err := os.ReadFile(filepath.Join(modelPath, descriptor.SpecialTokensMapFile))
 	if err != nil {
 		return nil, err
 	}
@@ -353,47 +516,67 @@ func loadTokenizer(modelPath string, maxLength int) (*tokenizer.Tokenizer, error
 	}
 
 	// Handle overflow when coercing to int, major hassle.
-	modelMaxLen := int(min(float64(math.MaxInt32), math.Abs(tokenizerConfig["model_max_length"].(float64))))
-	maxLength = min(maxLength, modelMaxLen)
+	// A tokenizer without model_max_length only has the limit of the options.
+	modelMaxLen := maxLength
+	if value, ok := tokenizerConfig["model_max_length"]; ok {
+		length, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("model %s: %s: model_max_length must be a number, got %T", descriptor.Model, descriptor.TokenizerConfigFile, value)
+		}
+		modelMaxLen = int(min(float64(math.MaxInt32), math.Abs(length)))
+	}
+	maxLength = min(maxLength, modelMaxLen) - reservedTokens
+	if maxLength <= 0 {
+		return nil, fmt.Errorf("model %s: a maximum length of %d leaves no room for the input tokens", descriptor.Model, maxLength+reservedTokens)
+	}
 
+	// LongestFirst trims the longest sequence of a pair first,
+	// so the short query of a cross-encoder pair is kept and the document is truncated.
 	tknzer.WithTruncation(&tokenizer.TruncationParams{
 		MaxLength: maxLength,
 		Strategy:  tokenizer.LongestFirst,
 		Stride:    0,
 	})
 
+	// Like the Python library, the pad token id defaults to 0.
+	padID := 0
+	if value, ok := config["pad_token_id"]; ok && value != nil {
+		id, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("model %s: %s: pad_token_id must be a number, got %T", descriptor.Model, descriptor.ConfigFile, value)
+		}
+		padID = int(id)
+	}
+
+	padToken, err := parseAddedToken(tokenizerConfig["pad_token"])
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %s: pad_token: %w", descriptor.Model, descriptor.TokenizerConfigFile, err)
+	}
+
 	paddingParams := tokenizer.PaddingParams{
 		// Strategy defaults to "BatchLongest"
 		Strategy:  *tokenizer.NewPaddingStrategy(),
 		Direction: tokenizer.Right,
-		PadId:     int(config["pad_token_id"].(float64)),
-		PadToken:  tokenizerConfig["pad_token"].(string),
+		PadId:     padID,
+		PadToken:  padToken.Content,
 		PadTypeId: 0,
 	}
 	tknzer.WithPadding(&paddingParams)
 
 	specialTokens := make([]tokenizer.AddedToken, 0)
 
-	for _, v := range tokensMap {
-		switch t := v.(type) {
-		case map[string]interface{}:
-			{
-				specialToken := tokenizer.AddedToken{
-					Content:    t["content"].(string),
-					SingleWord: t["single_word"].(bool),
-					LStrip:     t["lstrip"].(bool),
-					RStrip:     t["rstrip"].(bool),
-					Normalized: t["normalized"].(bool),
-				}
-				specialTokens = append(specialTokens, specialToken)
-			}
-		case string:
-			specialToken := tokenizer.AddedToken{
-				Content: t,
+	for name, v := range tokensMap {
+		// additional_special_tokens holds a list of tokens.
+		values, ok := v.([]interface{})
+		if !ok {
+			values = []interface{}{v}
+		}
+		for _, value := range values {
+			specialToken, err := parseAddedToken(value)
+			if err != nil {
+				return nil, fmt.Errorf("model %s: %s: %s: %w", descriptor.Model, descriptor.SpecialTokensMapFile, name, err)
 			}
 			specialTokens = append(specialTokens, specialToken)
-		default:
-			panic(fmt.Sprintf("unknown type for special_tokens_map.json%T", t))
 		}
 	}
 	tknzer.AddSpecialTokens(specialTokens)
@@ -401,136 +584,94 @@ func loadTokenizer(modelPath string, maxLength int) (*tokenizer.Tokenizer, error
 	return tknzer, nil
 }
 
-// Private function to get model information from the model name.
-func getModelInfo(model EmbeddingModel) (ModelInfo, error) {
-	for _, m := range ListSupportedModels() {
-		if m.Model == model {
-			return m, nil
+// Private function to parse a token of the tokenizer configuration files.
+// A token is either its content or an object with the content and the optional AddedToken flags.
+func parseAddedToken(value interface{}) (tokenizer.AddedToken, error) {
+	switch t := value.(type) {
+	case string:
+		return tokenizer.AddedToken{Content: t}, nil
+	case map[string]interface{}:
+		content, ok := t["content"].(string)
+		if !ok {
+			return tokenizer.AddedToken{}, fmt.Errorf("the token object has no content string, got %v", value)
 		}
+		token := tokenizer.AddedToken{Content: content}
+		for name, flag := range map[string]*bool{
+			"single_word": &token.SingleWord,
+			"lstrip":      &token.LStrip,
+			"rstrip":      &token.RStrip,
+			"normalized":  &token.Normalized,
+		} {
+			if v, ok := t[name]; ok {
+				if *flag, ok = v.(bool); !ok {
+					return tokenizer.AddedToken{}, fmt.Errorf("the %s flag of token %q must be a boolean, got %T", name, content, v)
+				}
+			}
+		}
+		return token, nil
+	case nil:
+		return tokenizer.AddedToken{}, errors.New("missing token")
+	default:
+		return tokenizer.AddedToken{}, fmt.Errorf("a token must be a string or an object, got %T", value)
 	}
-	return ModelInfo{}, fmt.Errorf("model %s not found", model)
-}
-
-// Private function to retrieve the model from the cache or download it
-// Returns the path to the model.
-func retrieveModel(model EmbeddingModel, cacheDir string, showDownloadProgress bool) (string, error) {
-	if _, err := os.Stat(filepath.Join(cacheDir, string(model))); !errors.Is(err, fs.ErrNotExist) {
-		return filepath.Join(cacheDir, string(model)), nil
-	}
-	return downloadFromGcs(model, cacheDir, showDownloadProgress)
-}
-
-// Private function to download the model from Google Cloud Storage.
-func downloadFromGcs(model EmbeddingModel, cacheDir string, showDownloadProgress bool) (string, error) {
-	// The MLE5Large model URL doesn't follow the same naming convention as the other models
-	// So, we tranform "fast-multilingual-e5-large" -> "intfloat-multilingual-e5-large" in the download URL
-	// The model directory name in the GCS storage is "fast-multilingual-e5-large", like the others
-	// modelName := model
-	// if model == MLE5Large {
-	// 	modelName = "intfloat" + model[strings.Index(string(model), "-"):]
-	// }
-
-	downloadURL := fmt.Sprintf("https://storage.googleapis.com/qdrant-fastembed/%s.tar.gz", model)
-
-	response, err := http.Get(downloadURL)
-	if err != nil {
-		return "", err
-	}
-	defer response.Body.Close()
-
-	if response.StatusCode < 200 || response.StatusCode > 299 {
-		return "", fmt.Errorf("model download failed: %s", response.Status)
-	}
-
-	if showDownloadProgress {
-		bar := progressbar.DefaultBytes(
-			response.ContentLength,
-			"Downloading "+string(model),
-		)
-		reader := progressbar.NewReader(response.Body, bar)
-		err = untar(&reader, cacheDir)
-	} else {
-		fmt.Printf("Downloading %s...", model)
-		err = untar(response.Body, cacheDir)
-	}
-
-	if err != nil {
-		return "", err
-	}
-
-	return filepath.Join(cacheDir, string(model)), nil
 }
 
-// Private function to untar the downloaded model from a .tar.gz file.
-func untar(tarball io.Reader, target string) error {
-	archive, err := gzip.NewReader(tarball)
-	if err != nil {
-		return err
-	}
-	defer archive.Close()
-
-	tarReader := tar.NewReader(archive)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-
-		path := filepath.Join(target, header.Name)
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(path, 0755); err != nil {
-				return err
-			}
-		case tar.TypeReg:
-			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-				return err
-			}
-
-			file, err := os.Create(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-			if _, err := io.Copy(file, tarReader); err != nil {
-				return err
-			}
+// Private function to get the information of a built-in model from the model name.
+func getBuiltinModelInfo(model EmbeddingModel) (ModelInfo, error) {
+	for _, m := range builtinModels() {
+		if m.Model == model {
+			return m, nil
 		}
 	}
-	return nil
+	return ModelInfo{}, fmt.Errorf("model %s not found", model)
 }
 
-// Private function to normalize a vector
+// Private function to L2 normalize a vector
+// The norm is clamped to epsilon so that a zero vector stays a zero vector instead of dividing by zero.
 // Based on https://github.com/qdrant/fastembed/blob/ca6f9d629ad14da1dfd094c846976b0c964b32cf/fastembed/embedding.py#L16
 func normalize(v []float32) []float32 {
-	norm := float32(0.0)
+	norm := float64(0.0)
 	for _, val := range v {
-		norm += val * val
+		norm += float64(val) * float64(val)
 	}
-	norm = float32(math.Sqrt(float64(norm)))
-	epsilon := float32(1e-12)
+	epsilon := 1e-12
+	norm = max(math.Sqrt(norm), epsilon)
 
 	normalized := make([]float32, len(v))
 	for i, val := range v {
-		normalized[i] = (val / norm) + epsilon
+		normalized[i] = float32(float64(val) / norm)
 	}
 
 	return normalized
 }
 
-// Private function to return the normalized embeddings from a flattened array with the given dimensions.
-func getEmbeddings(data []float32, dimensions []int64) []([]float32) {
+// Private function to return the pooled embeddings from a flattened array with the given dimensions.
+// The mask is the flattened attention mask of the batch, used to leave the padding out of the pooling.
+// The embeddings are L2 normalized if normalized is true.
+func getEmbeddings(data []float32, dimensions []int64, mask []int64, pooling PoolingStrategy, normalized bool) []([]float32) {
 	x, y, z := dimensions[0], dimensions[1], dimensions[2]
 	embeddings := make([][]float32, x)
 	var i int64
 	for i = 0; i < x; i++ {
 		startIndex := i * y * z
-		endIndex := startIndex + z
-		embeddings[i] = normalize(data[startIndex:endIndex])
+		endIndex := startIndex + y*z
+		tokenMask := mask[i*y : (i+1)*y]
+		embeddings[i] = pool(data[startIndex:endIndex], tokenMask, int(z), pooling)
+		if normalized {
+			embeddings[i] = normalize(embeddings[i])
+		}
+	}
+	return embeddings
+}
+
+// Private function to split the (batch size, dim) output of a model into embeddings.
+func getPooledEmbeddings(data []float32, dim int, normalized bool) []([]float32) {
+	embeddings := make([][]float32, len(data)/dim)
+	for i := range embeddings {
+		embeddings[i] = append([]float32(nil), data[i*dim:(i+1)*dim]...)
+		if normalized {
+			embeddings[i] = normalize(embeddings[i])
+		}
 	}
 	return embeddings
 }
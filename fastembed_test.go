@@ -1,7 +1,11 @@
 package fastembed_test
 
 import (
+	"context"
+	"errors"
+	"io"
 	"math"
+	"strings"
 	"testing"
 
 	fastembed "github.com/anush008/fastembed-go"
@@ -9,22 +13,29 @@ import (
 
 func TestCanonicalValues(t *testing.T) {
 	canonicalValues := map[fastembed.EmbeddingModel]([]float32){
-		fastembed.AllMiniLML6V2: []float32{0.02591, 0.00573, 0.01147, 0.03796, -0.02328},
 		fastembed.BGESmallEN:    []float32{-0.02313, -0.02552, 0.017357, -0.06393, -0.00061},
 		fastembed.BGEBaseEN:     []float32{0.01140, 0.03722, 0.02941, 0.01230, 0.03451},
 		fastembed.BGEBaseENV15:  []float32{0.01129394, 0.05493144, 0.02615099, 0.00328772, 0.02996045},
 		fastembed.BGESmallENV15: []float32{0.01522374, -0.02271799, 0.00860278, -0.07424029, 0.00386434},
 		fastembed.BGESmallZH:    []float32{-0.01023294, 0.07634465, 0.0691722, -0.04458365, -0.03160762},
+		// The mean pooled values of sentence-transformers.
+		fastembed.AllMiniLML6V2: []float32{-0.03447727, 0.03102320, 0.00673498, 0.02610897, -0.03936202},
+		// The values of the Python library, without the "query: " or "passage: " prefix.
+		fastembed.MLE5Large: []float32{0.0098, 0.0045, 0.0066, -0.0354, 0.0149},
+	}
+	// The values known to 4 decimals only.
+	tolerances := map[fastembed.EmbeddingModel]float64{
+		fastembed.MLE5Large: 1e-3,
 	}
 
 	for model, expected := range canonicalValues {
 		fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
 			Model: model,
 		})
-		defer fe.Destroy()
 		if err != nil {
 			t.Fatalf("Expected no error, got %v", err)
 		}
+		defer fe.Destroy()
 		input := []string{"hello world"}
 		result, err := fe.Embed(input, 1)
 		if err != nil {
@@ -36,6 +47,9 @@ func TestCanonicalValues(t *testing.T) {
 		}
 
 		epsilon := float64(1e-4)
+		if tolerance, ok := tolerances[model]; ok {
+			epsilon = tolerance
+		}
 		for i, v := range expected {
 			if math.Abs(float64(result[0][i]-v)) > epsilon {
 				t.Errorf("Element %d mismatch for %s: expected %.6f, got %.6f", i, model, v, result[0][i])
@@ -43,3 +57,298 @@ func TestCanonicalValues(t *testing.T) {
 		}
 	}
 }
+
+// The mean pooling of AllMiniLML6V2 is checked against the model run with its pooling set explicitly,
+// and its first token against the values computed before it defaulted to mean pooling.
+func TestAllMiniLML6V2Pooling(t *testing.T) {
+	embed := func(pooling fastembed.PoolingStrategy) []float32 {
+		fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{Model: fastembed.AllMiniLML6V2, Pooling: pooling})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		defer fe.Close()
+		result, err := fe.Embed([]string{"hello world"}, 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return result[0]
+	}
+
+	epsilon := float64(1e-4)
+	defaultPooled, meanPooled := embed(""), embed(fastembed.MeanPooling)
+	for i := range meanPooled {
+		if math.Abs(float64(defaultPooled[i]-meanPooled[i])) > epsilon {
+			t.Fatalf("Element %d mismatch: expected the mean pooled %.6f by default, got %.6f", i, meanPooled[i], defaultPooled[i])
+		}
+	}
+
+	clsPooled := embed(fastembed.CLSPooling)
+	for i, v := range []float32{0.02591, 0.00573, 0.01147, 0.03796, -0.02328} {
+		if math.Abs(float64(clsPooled[i]-v)) > epsilon {
+			t.Errorf("Element %d mismatch: expected %.6f, got %.6f", i, v, clsPooled[i])
+		}
+	}
+}
+
+// The multilingual e5 model is trained with the "query: " and "passage: " prefixes, and matches texts across languages.
+func TestMultilingualE5Large(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{Model: fastembed.MLE5Large})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	query, err := fe.QueryEmbed("how much protein should a female eat")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	passages, err := fe.PassageEmbed([]string{
+		"As a general guideline, the average requirement of protein for women ages 19 to 70 is 46 grams per day.",
+		"Der Eiffelturm wurde 1889 für die Weltausstellung in Paris errichtet.",
+	}, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	prefixed, err := fe.Embed([]string{
+		"query: how much protein should a female eat",
+		"passage: As a general guideline, the average requirement of protein for women ages 19 to 70 is 46 grams per day.",
+	}, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	epsilon := float64(1e-4)
+	if len(query) != 1024 {
+		t.Fatalf("Expected an embedding of length 1024, got %d", len(query))
+	}
+	for i := range query {
+		if math.Abs(float64(query[i]-prefixed[0][i])) > epsilon || math.Abs(float64(passages[0][i]-prefixed[1][i])) > epsilon {
+			t.Fatalf("Element %d mismatch: expected the embeddings of the prefixed texts", i)
+		}
+	}
+
+	// The query is closer to the passage answering it than to an unrelated passage in another language.
+	similarity := func(a, b []float32) float64 {
+		dot := 0.0
+		for i := range a {
+			dot += float64(a[i] * b[i])
+		}
+		return dot
+	}
+	if related, unrelated := similarity(query, passages[0]), similarity(query, passages[1]); related <= unrelated {
+		t.Errorf("Expected the related passage to score higher, got %f and %f", related, unrelated)
+	}
+
+	// The same question in French is matched to the English passage.
+	french, err := fe.QueryEmbed("combien de protéines une femme devrait-elle manger")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if related, unrelated := similarity(french, passages[0]), similarity(french, passages[1]); related <= unrelated {
+		t.Errorf("Expected the related passage to score higher for the French query, got %f and %f", related, unrelated)
+	}
+}
+
+// Every call reuses the session created in NewFlagEmbedding.
+func BenchmarkQueryEmbed(b *testing.B) {
+	fe, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		b.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Destroy()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := fe.QueryEmbed("hello world"); err != nil {
+			b.Fatalf("Expected no error, got %v", err)
+		}
+	}
+}
+
+// Baseline for BenchmarkQueryEmbed: loads the model for every call,
+// which is what each batch used to pay when the session was rebuilt per batch.
+func BenchmarkQueryEmbedWithModelLoad(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		fe, err := fastembed.NewFlagEmbedding(nil)
+		if err != nil {
+			b.Fatalf("Expected no error, got %v", err)
+		}
+		if _, err := fe.QueryEmbed("hello world"); err != nil {
+			b.Fatalf("Expected no error, got %v", err)
+		}
+		fe.Destroy()
+	}
+}
+
+func TestUnknownExecutionProvider(t *testing.T) {
+	// The options are checked before the model is retrieved, an empty offline cache never reaches the network.
+	_, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+		CacheDir:           t.TempDir(),
+		Offline:            true,
+		ExecutionProviders: []string{"NotAnExecutionProvider"},
+	})
+	if !errors.Is(err, fastembed.ErrUnknownExecutionProvider) {
+		t.Fatalf("Expected ErrUnknownExecutionProvider, got %v", err)
+	}
+
+	_, err = fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+		CacheDir: t.TempDir(),
+		Offline:  true,
+		SessionConfig: &fastembed.SessionConfig{
+			ExecutionProviders: []fastembed.ExecutionProvider{fastembed.CPUExecutionProvider, "NotAnExecutionProvider"},
+		},
+	})
+	if !errors.Is(err, fastembed.ErrUnknownExecutionProvider) {
+		t.Fatalf("Expected ErrUnknownExecutionProvider, got %v", err)
+	}
+}
+
+func TestUnsupportedGraphOptimizationLevel(t *testing.T) {
+	for _, level := range []fastembed.GraphOptimizationLevel{fastembed.GraphOptimizationBasic, "fastest"} {
+		_, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+			CacheDir:      t.TempDir(),
+			Offline:       true,
+			SessionConfig: &fastembed.SessionConfig{GraphOptimizationLevel: level},
+		})
+		if err == nil || !strings.Contains(err.Error(), "graph optimization level") {
+			t.Fatalf("Expected a graph optimization level error for %q, got %v", level, err)
+		}
+	}
+}
+
+func TestSessionConfig(t *testing.T) {
+	memArena := false
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+		SessionConfig: &fastembed.SessionConfig{
+			IntraOpNumThreads:  1,
+			InterOpNumThreads:  1,
+			CPUMemArena:        &memArena,
+			ExecutionProviders: []fastembed.ExecutionProvider{fastembed.CPUExecutionProvider},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Destroy()
+
+	if _, err := fe.QueryEmbed("hello world"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestInterleavedClose(t *testing.T) {
+	first, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+		Model: fastembed.AllMiniLML6V2,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Closing the first model must leave the shared environment usable by the second.
+	if err := first.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := second.QueryEmbed("hello world"); err != nil {
+		t.Fatalf("Expected no error after closing another model, got %v", err)
+	}
+
+	third, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := third.QueryEmbed("hello world"); err != nil {
+		t.Fatalf("Expected no error after closing another model, got %v", err)
+	}
+
+	var closer io.Closer = third
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Closing twice must be safe and must not release the environment again.
+	if err := third.Close(); err != nil {
+		t.Fatalf("Expected no error on double Close, got %v", err)
+	}
+	if err := first.Destroy(); err != nil {
+		t.Fatalf("Expected no error on Destroy after Close, got %v", err)
+	}
+
+	// The environment is initialized again for the next model.
+	fourth, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fourth.Close()
+	if _, err := fourth.QueryEmbed("hello world"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+}
+
+func TestEmbedContextCancelled(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	input := []string{"hello world", "fastembed-go is licensed under MIT"}
+	if _, err := fe.EmbedContext(ctx, input, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	if _, err := fe.PassageEmbedContext(ctx, input, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	if _, err := fe.QueryEmbedContext(ctx, "hello world"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	result, err := fe.EmbedContext(context.Background(), input, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result) != len(input) {
+		t.Errorf("Expected result length %v, got %v", len(input), len(result))
+	}
+}
+
+func TestMaxConcurrentBatches(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+		MaxConcurrentBatches: 2,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	input := make([]string, 100)
+	for i := range input {
+		input[i] = "hello world"
+	}
+	result, err := fe.Embed(input, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result) != len(input) {
+		t.Errorf("Expected result length %v, got %v", len(input), len(result))
+	}
+	for i, v := range result {
+		if len(v) == 0 {
+			t.Fatalf("Expected an embedding at index %d", i)
+		}
+	}
+
+	if err := fe.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := fe.Embed(input, 3); !errors.Is(err, fastembed.ErrModelClosed) {
+		t.Errorf("Expected %v, got %v", fastembed.ErrModelClosed, err)
+	}
+}
@@ -3,6 +3,7 @@ module github.com/anush008/fastembed-go
 go 1.21
 
 require (
+	github.com/rivo/uniseg v0.4.6
 	github.com/schollz/progressbar/v3 v3.14.1
 	github.com/sugarme/tokenizer v0.2.3-0.20230829214935-448e79b1ed65 // A major fix isn't on the latest release yet. https://github.com/sugarme/tokenizer/commit/793eb3679937ba487d0e2564b241be6685cb03cf.
 	github.com/yalue/onnxruntime_go v1.7.0
@@ -12,7 +13,6 @@ require (
 	github.com/emirpasic/gods v1.18.1 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/mitchellh/colorstring v0.0.0-20190213212951-d06e56a500db // indirect
-	github.com/rivo/uniseg v0.4.6 // indirect
 	github.com/schollz/progressbar/v2 v2.15.0 // indirect
 	github.com/sugarme/regexpset v0.0.0-20200920021344-4d4ec8eaf93c // indirect
 	golang.org/x/sys v0.16.0 // indirect
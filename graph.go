@@ -0,0 +1,151 @@
+package fastembed
+
+import (
+	"fmt"
+	"slices"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Struct describing the ONNX inputs and outputs a model type can use,
+// matched against the ones declared by the ONNX graph when the model is loaded
+// inputs: The inputs the model type can feed, the first one being required
+// inputType: The element type of the inputs
+// outputs: The outputs the model type can read by order of preference, used when the descriptor has no OutputName
+// ranks: The accepted ranks of the output, 2 for (batch size, dim) and 3 for (batch size, sequence length, dim)
+type graphSpec struct {
+	inputs    []string
+	inputType ort.TensorElementDataType
+	outputs   []string
+	ranks     []int
+}
+
+// The inputs of the text models, fed in the order the ONNX graph declares them.
+var textInputs = []string{inputIDsName, attentionMaskName, tokenTypeIDsName}
+
+// The ranks of the outputs of the conventional names, for the graphs that cannot be read.
+var outputRanks = map[string]int{
+	"sentence_embedding": 2,
+	"image_embeds":       2,
+	"last_hidden_state":  3,
+	"token_embeddings":   3,
+}
+
+// Private function to return the spec of a text model type, reading one of the given outputs.
+func textGraphSpec(ranks []int, outputs ...string) graphSpec {
+	return graphSpec{inputs: textInputs, inputType: ort.TensorElementDataTypeInt64, outputs: outputs, ranks: ranks}
+}
+
+// Private function to read the inputs and outputs of an ONNX file and match the descriptor against them
+// The graph cannot be read from the file alone when the weights are stored as external data, as done for models over 2GB.
+// The descriptor names are then used as they are, once checked against the spec, defaulting to all the inputs and the first output of the spec,
+// and the session creation reports the mismatches. The rank of the output is the one of the spec if it accepts a single one,
+// else the one of its conventional name, an error being returned for other outputs.
+func inspectGraph(file string, descriptor ModelDescriptor, spec graphSpec) (ModelDescriptor, ort.InputOutputInfo, error) {
+	inputs, outputs, err := ort.GetInputOutputInfo(file)
+	if err != nil {
+		for _, name := range descriptor.InputNames {
+			if !slices.Contains(spec.inputs, name) {
+				return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the input %q is not supported, the supported inputs are %q", descriptor.Model, name, spec.inputs)
+			}
+		}
+		if len(descriptor.InputNames) == 0 {
+			descriptor.InputNames = slices.Clone(spec.inputs)
+		}
+		if descriptor.OutputName == "" {
+			descriptor.OutputName = spec.outputs[0]
+		}
+		rank, known := outputRanks[descriptor.OutputName]
+		switch {
+		case !known && len(spec.ranks) == 1:
+			rank = spec.ranks[0]
+		case !known:
+			return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the rank of the output %q is unknown as the ONNX graph cannot be read: %w", descriptor.Model, descriptor.OutputName, err)
+		case !slices.Contains(spec.ranks, rank):
+			return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the output %q has a rank of %d, expected a rank of %v", descriptor.Model, descriptor.OutputName, rank, spec.ranks)
+		}
+		// (batch size, dim) or (batch size, sequence length, dim), the dynamic dimensions being negative.
+		dimensions := make(ort.Shape, rank)
+		for i := range dimensions {
+			dimensions[i] = -1
+		}
+		dimensions[rank-1] = int64(descriptor.Dim)
+		return descriptor, ort.InputOutputInfo{Name: descriptor.OutputName, Dimensions: dimensions, DataType: ort.TensorElementDataTypeFloat}, nil
+	}
+	return resolveGraph(descriptor, spec, inputs, outputs)
+}
+
+// Private function to match a descriptor against the inputs and outputs of its ONNX graph
+// The inputs default to the ones of the graph, and the output to the first output of the spec found in the graph.
+// Returns the descriptor with its InputNames and OutputName set, and the information of its output.
+func resolveGraph(descriptor ModelDescriptor, spec graphSpec, inputs, outputs []ort.InputOutputInfo) (ModelDescriptor, ort.InputOutputInfo, error) {
+	inputNames := graphNames(inputs)
+	if len(descriptor.InputNames) == 0 {
+		for _, input := range inputs {
+			if !slices.Contains(spec.inputs, input.Name) {
+				return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the ONNX input %q is not supported, the supported inputs are %q", descriptor.Model, input.Name, spec.inputs)
+			}
+		}
+		descriptor.InputNames = inputNames
+	} else {
+		for _, name := range descriptor.InputNames {
+			if !slices.Contains(spec.inputs, name) {
+				return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the input %q is not supported, the supported inputs are %q", descriptor.Model, name, spec.inputs)
+			}
+			if !slices.Contains(inputNames, name) {
+				return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: %q is not an input of the ONNX model, its inputs are %q", descriptor.Model, name, inputNames)
+			}
+		}
+		for _, name := range inputNames {
+			if !slices.Contains(descriptor.InputNames, name) {
+				return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the ONNX input %q is missing from InputNames", descriptor.Model, name)
+			}
+		}
+	}
+	if !slices.Contains(descriptor.InputNames, spec.inputs[0]) {
+		return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the %q input is required, the ONNX model has %q", descriptor.Model, spec.inputs[0], inputNames)
+	}
+	for _, input := range inputs {
+		if input.DataType != spec.inputType {
+			return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the ONNX input %q is of type %s, expected %s", descriptor.Model, input.Name, input.DataType, spec.inputType)
+		}
+	}
+
+	outputNames := graphNames(outputs)
+	if descriptor.OutputName == "" {
+		for _, name := range spec.outputs {
+			if slices.Contains(outputNames, name) {
+				descriptor.OutputName = name
+				break
+			}
+		}
+		if descriptor.OutputName == "" {
+			return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: none of the ONNX outputs %q is supported, expected one of %q or an OutputName", descriptor.Model, outputNames, spec.outputs)
+		}
+	}
+	index := slices.Index(outputNames, descriptor.OutputName)
+	if index < 0 {
+		return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: %q is not an output of the ONNX model, its outputs are %q", descriptor.Model, descriptor.OutputName, outputNames)
+	}
+	output := outputs[index]
+	if outputType := ort.TensorElementDataType(ort.TensorElementDataTypeFloat); output.DataType != outputType {
+		return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the ONNX output %q is of type %s, expected %s", descriptor.Model, output.Name, output.DataType, outputType)
+	}
+	if !slices.Contains(spec.ranks, len(output.Dimensions)) {
+		return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the ONNX output %q has shape %s, expected a rank of %v", descriptor.Model, output.Name, output.Dimensions, spec.ranks)
+	}
+	// Dynamic dimensions are negative.
+	if dim := output.Dimensions[len(output.Dimensions)-1]; dim > 0 && dim != int64(descriptor.Dim) {
+		return descriptor, ort.InputOutputInfo{}, fmt.Errorf("model %s: the ONNX output %q has a dimension of %d, the descriptor has %d", descriptor.Model, output.Name, dim, descriptor.Dim)
+	}
+	return descriptor, output, nil
+}
+
+// Private function to list the names of ONNX inputs or outputs.
+func graphNames(infos []ort.InputOutputInfo) []string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+	return names
+}
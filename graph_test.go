@@ -0,0 +1,262 @@
+package fastembed_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Returns the information of an int64 input of shape (batch size, sequence length).
+func tokenInput(name string) ort.InputOutputInfo {
+	return ort.InputOutputInfo{Name: name, Dimensions: ort.NewShape(-1, -1), DataType: ort.TensorElementDataTypeInt64}
+}
+
+// Returns the information of a float output of the given shape.
+func floatOutput(name string, dimensions ...int64) ort.InputOutputInfo {
+	return ort.InputOutputInfo{Name: name, Dimensions: ort.NewShape(dimensions...), DataType: ort.TensorElementDataTypeFloat}
+}
+
+func TestResolveDenseGraph(t *testing.T) {
+	descriptor := fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: "test-graph", Dim: 384}}
+	bertInputs := []ort.InputOutputInfo{tokenInput("input_ids"), tokenInput("attention_mask"), tokenInput("token_type_ids")}
+	xlmrInputs := []ort.InputOutputInfo{tokenInput("input_ids"), tokenInput("attention_mask")}
+	tokenOutput := floatOutput("last_hidden_state", -1, -1, 384)
+	pooledOutput := floatOutput("sentence_embedding", -1, 384)
+
+	testCases := []struct {
+		name           string
+		inputNames     []string
+		outputName     string
+		customPooling  bool
+		inputs         []ort.InputOutputInfo
+		outputs        []ort.InputOutputInfo
+		expectedInputs []string
+		expectedOutput string
+		expectedPooled bool
+	}{
+		{
+			name:           "token embeddings",
+			inputs:         bertInputs,
+			outputs:        []ort.InputOutputInfo{tokenOutput},
+			expectedInputs: []string{"input_ids", "attention_mask", "token_type_ids"},
+			expectedOutput: "last_hidden_state",
+		},
+		{
+			name:           "no token type ids",
+			inputs:         xlmrInputs,
+			outputs:        []ort.InputOutputInfo{tokenOutput},
+			expectedInputs: []string{"input_ids", "attention_mask"},
+			expectedOutput: "last_hidden_state",
+		},
+		{
+			name:           "pooled output",
+			inputs:         xlmrInputs,
+			outputs:        []ort.InputOutputInfo{floatOutput("token_embeddings", -1, -1, 384), pooledOutput},
+			expectedInputs: []string{"input_ids", "attention_mask"},
+			expectedOutput: "sentence_embedding",
+			expectedPooled: true,
+		},
+		{
+			name:           "custom pooling",
+			customPooling:  true,
+			inputs:         xlmrInputs,
+			outputs:        []ort.InputOutputInfo{pooledOutput, tokenOutput},
+			expectedInputs: []string{"input_ids", "attention_mask"},
+			expectedOutput: "last_hidden_state",
+		},
+		{
+			name:           "explicit names",
+			inputNames:     []string{"attention_mask", "input_ids"},
+			outputName:     "last_hidden_state",
+			inputs:         xlmrInputs,
+			outputs:        []ort.InputOutputInfo{pooledOutput, tokenOutput},
+			expectedInputs: []string{"attention_mask", "input_ids"},
+			expectedOutput: "last_hidden_state",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := descriptor
+			d.InputNames = tc.inputNames
+			d.OutputName = tc.outputName
+			resolved, pooled, err := fastembed.ResolveDenseGraph(d, tc.customPooling, tc.inputs, tc.outputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resolved.InputNames, tc.expectedInputs) {
+				t.Errorf("Expected inputs %q, got %q", tc.expectedInputs, resolved.InputNames)
+			}
+			if resolved.OutputName != tc.expectedOutput {
+				t.Errorf("Expected output %q, got %q", tc.expectedOutput, resolved.OutputName)
+			}
+			if pooled != tc.expectedPooled {
+				t.Errorf("Expected pooled to be %v, got %v", tc.expectedPooled, pooled)
+			}
+		})
+	}
+}
+
+func TestResolveDenseGraphErrors(t *testing.T) {
+	descriptor := fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: "test-graph", Dim: 384}}
+	inputs := []ort.InputOutputInfo{tokenInput("input_ids"), tokenInput("attention_mask")}
+	outputs := []ort.InputOutputInfo{floatOutput("last_hidden_state", -1, -1, 384)}
+
+	testCases := []struct {
+		name       string
+		inputNames []string
+		outputName string
+		dim        int
+		inputs     []ort.InputOutputInfo
+		outputs    []ort.InputOutputInfo
+		expected   string
+	}{
+		{
+			name:     "unsupported input",
+			inputs:   append([]ort.InputOutputInfo{tokenInput("position_ids")}, inputs...),
+			outputs:  outputs,
+			expected: `the ONNX input "position_ids" is not supported`,
+		},
+		{
+			name:     "missing input ids",
+			inputs:   inputs[1:],
+			outputs:  outputs,
+			expected: `the "input_ids" input is required`,
+		},
+		{
+			name:       "input not in the model",
+			inputNames: []string{"input_ids", "attention_mask", "token_type_ids"},
+			inputs:     inputs,
+			outputs:    outputs,
+			expected:   `"token_type_ids" is not an input of the ONNX model`,
+		},
+		{
+			name:       "input not fed",
+			inputNames: []string{"input_ids"},
+			inputs:     inputs,
+			outputs:    outputs,
+			expected:   `the ONNX input "attention_mask" is missing from InputNames`,
+		},
+		{
+			name: "input type",
+			inputs: []ort.InputOutputInfo{
+				{Name: "input_ids", Dimensions: ort.NewShape(-1, -1), DataType: ort.TensorElementDataTypeInt32},
+			},
+			outputs:  outputs,
+			expected: `the ONNX input "input_ids" is of type`,
+		},
+		{
+			name:     "no supported output",
+			inputs:   inputs,
+			outputs:  []ort.InputOutputInfo{floatOutput("pooler_output", -1, 384)},
+			expected: `none of the ONNX outputs ["pooler_output"] is supported`,
+		},
+		{
+			name:       "output not in the model",
+			outputName: "embeddings",
+			inputs:     inputs,
+			outputs:    outputs,
+			expected:   `"embeddings" is not an output of the ONNX model`,
+		},
+		{
+			name:     "output rank",
+			inputs:   inputs,
+			outputs:  []ort.InputOutputInfo{floatOutput("last_hidden_state", -1)},
+			expected: `has shape [-1], expected a rank of [2 3]`,
+		},
+		{
+			name:     "output dimension",
+			dim:      768,
+			inputs:   inputs,
+			outputs:  outputs,
+			expected: `has a dimension of 384, the descriptor has 768`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := descriptor
+			d.InputNames = tc.inputNames
+			d.OutputName = tc.outputName
+			if tc.dim > 0 {
+				d.Dim = tc.dim
+			}
+			_, _, err := fastembed.ResolveDenseGraph(d, false, tc.inputs, tc.outputs)
+			if err == nil || !strings.Contains(err.Error(), tc.expected) {
+				t.Errorf("Expected an error containing %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestInspectUnreadableDenseGraph(t *testing.T) {
+	// The graph of a missing file cannot be read, as for the models with external data.
+	path := filepath.Join(t.TempDir(), "model.onnx")
+	descriptor := fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: "test-graph", Dim: 384}}
+
+	testCases := []struct {
+		name           string
+		inputNames     []string
+		outputName     string
+		customPooling  bool
+		expectedInputs []string
+		expectedOutput string
+		expectedPooled bool
+	}{
+		{
+			name:           "pooled output",
+			expectedInputs: []string{"input_ids", "attention_mask", "token_type_ids"},
+			expectedOutput: "sentence_embedding",
+			expectedPooled: true,
+		},
+		{
+			name:           "custom pooling",
+			customPooling:  true,
+			inputNames:     []string{"input_ids", "attention_mask"},
+			expectedInputs: []string{"input_ids", "attention_mask"},
+			expectedOutput: "last_hidden_state",
+		},
+		{
+			name:           "token embeddings",
+			outputName:     "token_embeddings",
+			expectedInputs: []string{"input_ids", "attention_mask", "token_type_ids"},
+			expectedOutput: "token_embeddings",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := descriptor
+			d.InputNames = tc.inputNames
+			d.OutputName = tc.outputName
+			resolved, pooled, err := fastembed.InspectDenseGraph(path, d, tc.customPooling)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resolved.InputNames, tc.expectedInputs) {
+				t.Errorf("Expected inputs %q, got %q", tc.expectedInputs, resolved.InputNames)
+			}
+			if resolved.OutputName != tc.expectedOutput {
+				t.Errorf("Expected output %q, got %q", tc.expectedOutput, resolved.OutputName)
+			}
+			if pooled != tc.expectedPooled {
+				t.Errorf("Expected pooled to be %v, got %v", tc.expectedPooled, pooled)
+			}
+		})
+	}
+
+	// An output of another name may be pooled or not.
+	d := descriptor
+	d.OutputName = "embeddings"
+	if _, _, err := fastembed.InspectDenseGraph(path, d, false); err == nil || !strings.Contains(err.Error(), `the rank of the output "embeddings" is unknown`) {
+		t.Errorf("Expected an unknown rank error, got %v", err)
+	}
+	d.OutputName = ""
+	d.InputNames = []string{"pixel_values"}
+	if _, _, err := fastembed.InspectDenseGraph(path, d, false); err == nil || !strings.Contains(err.Error(), `the input "pixel_values" is not supported`) {
+		t.Errorf("Expected an unsupported input error, got %v", err)
+	}
+}
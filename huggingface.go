@@ -0,0 +1,284 @@
+package fastembed
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// The Hugging Face Hub used when HuggingFaceSource.Endpoint is not set.
+const defaultHuggingFaceEndpoint = "https://huggingface.co"
+
+// Source of the files of a Hugging Face Hub model repository
+// Repo: The repository, such as "BAAI/bge-small-en-v1.5"
+// Revision: The branch, tag or commit hash to download, defaults to "main"
+// Token: The access token of private or gated repositories, defaults to the HF_TOKEN environment variable
+// Endpoint: The URL of the Hub, defaults to "https://huggingface.co"
+// Files: Additional files to download, such as the external data of large ONNX models
+// NOTE:
+// The files are cached with the layout of the Hugging Face cache, in a "models--{owner}--{name}" directory of the cache directory:
+// the files are stored once in "blobs", named after their SHA-256 digest, and linked from "snapshots/{commit}",
+// while "refs/{revision}" holds the commit of the revision.
+// Pointing InitOptions.CacheDir to the Hugging Face cache reuses the models it already holds.
+type HuggingFaceSource struct {
+	Repo     string
+	Revision string
+	Token    string
+	Endpoint string
+	Files    []string
+}
+
+// Function to return the snapshot directory of the revision, downloading the model files if needed
+// The files needed to load the model and the Files of the source are required,
+// the tokenizer files for the text models and the preprocessor configuration for the image models.
+func (s HuggingFaceSource) Fetch(descriptor ModelDescriptor, options *InitOptions) (string, error) {
+	if s.Repo == "" {
+		return "", fmt.Errorf("model %s: a Hugging Face repository is required", descriptor.Model)
+	}
+	if err := validateRepo(s.Repo); err != nil {
+		return "", fmt.Errorf("model %s: %w", descriptor.Model, err)
+	}
+	revision := s.revision()
+	if !filepath.IsLocal(filepath.FromSlash(revision)) {
+		return "", fmt.Errorf("model %s: invalid revision %q", descriptor.Model, revision)
+	}
+	repoDir := filepath.Join(options.CacheDir, "models--"+strings.ReplaceAll(s.Repo, "/", "--"))
+
+	if options.Offline {
+		return s.cachedSnapshot(repoDir, revision, descriptor)
+	}
+
+	// The ONNX file is downloaded last, so a snapshot holding it is complete.
+	if commit, err := cachedCommit(repoDir, revision); err == nil {
+		snapshot := filepath.Join(repoDir, "snapshots", commit)
+		if _, err := os.Stat(filepath.Join(snapshot, descriptor.ModelFile)); err == nil {
+			return snapshot, nil
+		}
+	}
+
+	d := newDownloader(options)
+	commit, err := s.resolveCommit(d.client, revision)
+	if err != nil {
+		return "", fmt.Errorf("model %s: %w", descriptor.Model, err)
+	}
+
+	snapshot := filepath.Join(repoDir, "snapshots", commit)
+	// The files are those checked by the offline mode and needed by the loaders, the ONNX file last.
+	files := slices.DeleteFunc(descriptor.requiredFiles(), func(file string) bool {
+		return file == descriptor.ModelFile
+	})
+	files = append(append(files, s.Files...), descriptor.ModelFile)
+	for _, file := range files {
+		if err := s.downloadFile(d, descriptor.Model, repoDir, commit, file); err != nil {
+			return "", fmt.Errorf("model %s: %w", descriptor.Model, err)
+		}
+	}
+
+	if err := verifyModelFiles(snapshot, descriptor, options.ProgressFunc); err != nil {
+		return "", err
+	}
+	if revision != commit {
+		if err := writeRef(repoDir, revision, commit); err != nil {
+			return "", err
+		}
+	}
+	return snapshot, nil
+}
+
+// Private function to check that a repository is named "{owner}/{name}", as it names a directory of the cache directory.
+func validateRepo(repo string) error {
+	owner, name, found := strings.Cut(repo, "/")
+	for _, part := range []string{owner, name} {
+		if !found || part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return fmt.Errorf("invalid Hugging Face repository %q, expected {owner}/{name}", repo)
+		}
+	}
+	return nil
+}
+
+// Private function to return the cached snapshot of the revision, without using the network.
+// The error wraps ErrModelNotCached and lists the missing files.
+func (s HuggingFaceSource) cachedSnapshot(repoDir, revision string, descriptor ModelDescriptor) (string, error) {
+	commit, err := cachedCommit(repoDir, revision)
+	if err != nil {
+		return "", fmt.Errorf("%w: model %s: revision %s of %s is not in %s", ErrModelNotCached, descriptor.Model, revision, s.Repo, repoDir)
+	}
+
+	snapshot := filepath.Join(repoDir, "snapshots", commit)
+	if err := checkModelFiles(snapshot, descriptor, s.Files...); err != nil {
+		return "", err
+	}
+	return snapshot, nil
+}
+
+// Private function to return the revision to download.
+func (s HuggingFaceSource) revision() string {
+	if s.Revision == "" {
+		return "main"
+	}
+	return s.Revision
+}
+
+// Private function to return the URL of the Hub.
+func (s HuggingFaceSource) endpoint() string {
+	if s.Endpoint == "" {
+		return defaultHuggingFaceEndpoint
+	}
+	return strings.TrimSuffix(s.Endpoint, "/")
+}
+
+// Private function to return the headers authenticating the requests to the Hub, if there is a token.
+func (s HuggingFaceSource) header() http.Header {
+	token := s.Token
+	if token == "" {
+		token = os.Getenv("HF_TOKEN")
+	}
+	if token == "" {
+		return nil
+	}
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// Private function to get the commit hash of a revision from the Hub API.
+// A revision that is already a commit hash is returned as is, so pinned revisions are never looked up.
+func (s HuggingFaceSource) resolveCommit(client *http.Client, revision string) (string, error) {
+	if isCommitHash(revision) {
+		return revision, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/models/%s/revision/%s", s.endpoint(), s.Repo, url.PathEscape(revision)), nil)
+	if err != nil {
+		return "", err
+	}
+	for key, values := range s.header() {
+		request.Header[key] = values
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("resolving revision %s of %s: %s", revision, s.Repo, response.Status)
+	}
+
+	var info struct {
+		SHA string `json:"sha"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("resolving revision %s of %s: %w", revision, s.Repo, err)
+	}
+	if !isCommitHash(info.SHA) {
+		return "", fmt.Errorf("resolving revision %s of %s: invalid commit %q", revision, s.Repo, info.SHA)
+	}
+	return info.SHA, nil
+}
+
+// Private function to download a file of the repository at the given commit into the blobs,
+// and link it from the snapshot of the commit. Files already in the snapshot are not downloaded again.
+func (s HuggingFaceSource) downloadFile(d *downloader, model EmbeddingModel, repoDir, commit, file string) error {
+	if !filepath.IsLocal(filepath.FromSlash(file)) {
+		return fmt.Errorf("invalid file path %q", file)
+	}
+	snapshotPath := filepath.Join(repoDir, "snapshots", commit, filepath.FromSlash(file))
+	if _, err := os.Stat(snapshotPath); err == nil {
+		return nil
+	}
+
+	blobsDir := filepath.Join(repoDir, "blobs")
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		return err
+	}
+
+	// The partial file is named after the commit and the file, so an interrupted download is resumed.
+	partPath := filepath.Join(blobsDir, commit+"-"+strings.ReplaceAll(file, "/", "--")+".incomplete")
+	fileURL := fmt.Sprintf("%s/%s/resolve/%s/%s", s.endpoint(), s.Repo, commit, escapePath(file))
+	if err := d.downloadFile(fileURL, partPath, model, file, s.header()); err != nil {
+		// A missing file leaves an empty partial file behind.
+		var statusErr *downloadStatusError
+		if errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound {
+			os.Remove(partPath)
+		}
+		return fmt.Errorf("downloading %s: %w", file, err)
+	}
+
+	digest, err := fileSHA256(partPath, nil)
+	if err != nil {
+		return err
+	}
+	blobPath := filepath.Join(blobsDir, digest)
+	if err := os.Rename(partPath, blobPath); err != nil {
+		return err
+	}
+	return linkBlob(blobPath, snapshotPath)
+}
+
+// Private function to link a snapshot file to its blob, relatively like the Hugging Face cache.
+// A hard link is used where symbolic links are not available.
+func linkBlob(blobPath, snapshotPath string) error {
+	if err := os.MkdirAll(filepath.Dir(snapshotPath), 0755); err != nil {
+		return err
+	}
+
+	target, err := filepath.Rel(filepath.Dir(snapshotPath), blobPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(target, snapshotPath); err != nil {
+		return os.Link(blobPath, snapshotPath)
+	}
+	return nil
+}
+
+// Private function to read the commit a revision was resolved to when last downloaded.
+func cachedCommit(repoDir, revision string) (string, error) {
+	if isCommitHash(revision) {
+		return revision, nil
+	}
+
+	ref, err := os.ReadFile(filepath.Join(repoDir, "refs", filepath.FromSlash(revision)))
+	if err != nil {
+		return "", err
+	}
+	commit := strings.TrimSpace(string(ref))
+	if !isCommitHash(commit) {
+		return "", fmt.Errorf("invalid commit %q in the %s ref", commit, revision)
+	}
+	return commit, nil
+}
+
+// Private function to record the commit a revision resolved to.
+func writeRef(repoDir, revision, commit string) error {
+	refPath := filepath.Join(repoDir, "refs", filepath.FromSlash(revision))
+	if err := os.MkdirAll(filepath.Dir(refPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(refPath, []byte(commit), 0644)
+}
+
+// Private function to tell whether a revision is a full git commit hash.
+func isCommitHash(revision string) bool {
+	if len(revision) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(revision)
+	return err == nil
+}
+
+// Private function to escape the segments of a "/" separated path for a URL.
+func escapePath(filePath string) string {
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,214 @@
+package fastembed_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+const testCommit = "0123456789abcdef0123456789abcdef01234567"
+
+// Server mimicking the Hugging Face Hub API for a single repository at testCommit.
+// The requested paths are recorded.
+type hubServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newHubServer(t *testing.T, repo, token string, files map[string]string) *hubServer {
+	t.Helper()
+	s := &hubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path)
+		s.mu.Unlock()
+
+		if token != "" && r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path == "/api/models/"+repo+"/revision/main" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"sha": testCommit})
+			return
+		}
+		file, found := strings.CutPrefix(r.URL.Path, "/"+repo+"/resolve/"+testCommit+"/")
+		content, ok := files[file]
+		if !found || !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Registers a model with its ONNX file in a subdirectory, as found in many Hub repositories.
+func registerHubModel(t *testing.T, model fastembed.EmbeddingModel) {
+	t.Helper()
+	err := fastembed.RegisterModel(fastembed.ModelDescriptor{
+		ModelInfo: fastembed.ModelInfo{Model: model, Dim: 4},
+		ModelFile: "onnx/model.onnx",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns the files of a text model registered with registerHubModel.
+func hubModelFiles() map[string]string {
+	return map[string]string{
+		"onnx/model.onnx":         "onnx",
+		"tokenizer.json":          "{}",
+		"config.json":             `{"hidden_size": 4}`,
+		"tokenizer_config.json":   `{"pad_token": "[PAD]"}`,
+		"special_tokens_map.json": `{"pad_token": "[PAD]"}`,
+	}
+}
+
+func TestHuggingFaceSource(t *testing.T) {
+	registerHubModel(t, "hf-test-model")
+	files := hubModelFiles()
+	server := newHubServer(t, "org/model", "secret", files)
+
+	showDownloadProgress := false
+	cacheDir := t.TempDir()
+	options := &fastembed.InitOptions{
+		CacheDir:             cacheDir,
+		ShowDownloadProgress: &showDownloadProgress,
+		HTTPClient:           server.Client(),
+		Source: fastembed.HuggingFaceSource{
+			Repo:     "org/model",
+			Token:    "secret",
+			Endpoint: server.URL,
+		},
+	}
+
+	modelPath, err := fastembed.RetrieveModel("hf-test-model", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := filepath.Join(cacheDir, "models--org--model")
+	if expected := filepath.Join(repoDir, "snapshots", testCommit); modelPath != expected {
+		t.Fatalf("Expected the snapshot %s, got %s", expected, modelPath)
+	}
+
+	for file, content := range files {
+		data, err := os.ReadFile(filepath.Join(modelPath, filepath.FromSlash(file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %q in %s, got %q", content, file, data)
+		}
+		if _, err := os.Stat(filepath.Join(repoDir, "blobs", sha256Hex([]byte(content)))); err != nil {
+			t.Errorf("Expected %s in the blobs: %v", file, err)
+		}
+	}
+	ref, err := os.ReadFile(filepath.Join(repoDir, "refs", "main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ref) != testCommit {
+		t.Errorf("Expected the main ref to hold %s, got %s", testCommit, ref)
+	}
+
+	// The cached snapshot is used without any request.
+	server.Close()
+	if cachedPath, err := fastembed.RetrieveModel("hf-test-model", options); err != nil || cachedPath != modelPath {
+		t.Errorf("Expected the cached snapshot, got %s, %v", cachedPath, err)
+	}
+}
+
+func TestHuggingFaceSourcePinnedRevision(t *testing.T) {
+	registerHubModel(t, "hf-pinned-test-model")
+	server := newHubServer(t, "org/model", "", hubModelFiles())
+
+	showDownloadProgress := false
+	_, err := fastembed.RetrieveModel("hf-pinned-test-model", &fastembed.InitOptions{
+		CacheDir:             t.TempDir(),
+		ShowDownloadProgress: &showDownloadProgress,
+		HTTPClient:           server.Client(),
+		Source:               fastembed.HuggingFaceSource{Repo: "org/model", Revision: testCommit, Endpoint: server.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, request := range server.requests {
+		if strings.HasPrefix(request, "/api/") {
+			t.Errorf("Expected a pinned revision not to be resolved, got a request for %s", request)
+		}
+	}
+}
+
+func TestHuggingFaceSourceErrors(t *testing.T) {
+	registerHubModel(t, "hf-missing-test-model")
+	testCases := map[string]struct {
+		token   string
+		missing string
+		source  fastembed.HuggingFaceSource
+	}{
+		"no repository":          {source: fastembed.HuggingFaceSource{}},
+		"missing token":          {token: "secret", source: fastembed.HuggingFaceSource{Repo: "org/model"}},
+		"missing file":           {source: fastembed.HuggingFaceSource{Repo: "org/model", Files: []string{"onnx/model.onnx_data"}}},
+		"missing tokenizer file": {missing: "special_tokens_map.json", source: fastembed.HuggingFaceSource{Repo: "org/model"}},
+		"invalid revision":       {source: fastembed.HuggingFaceSource{Repo: "org/model", Revision: "../main"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			files := hubModelFiles()
+			delete(files, tc.missing)
+			server := newHubServer(t, "org/model", tc.token, files)
+			tc.source.Endpoint = server.URL
+
+			showDownloadProgress := false
+			_, err := fastembed.RetrieveModel("hf-missing-test-model", &fastembed.InitOptions{
+				CacheDir:             t.TempDir(),
+				ShowDownloadProgress: &showDownloadProgress,
+				HTTPClient:           server.Client(),
+				Source:               tc.source,
+			})
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+		})
+	}
+}
+
+func TestHuggingFaceSourceInvalidRepo(t *testing.T) {
+	registerHubModel(t, "hf-invalid-repo-test-model")
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// The repository names a directory of the cache directory, it must not point outside of it.
+	for _, repo := range []string{"model", "org/", "/model", "../model", "org/..", "./model", "org/model/onnx", `org\..\..\model`} {
+		showDownloadProgress := false
+		_, err := fastembed.RetrieveModel("hf-invalid-repo-test-model", &fastembed.InitOptions{
+			CacheDir:             t.TempDir(),
+			ShowDownloadProgress: &showDownloadProgress,
+			HTTPClient:           server.Client(),
+			Source:               fastembed.HuggingFaceSource{Repo: repo, Endpoint: server.URL},
+		})
+		if err == nil || !strings.Contains(err.Error(), "invalid Hugging Face repository") {
+			t.Errorf("Expected an invalid repository error for %q, got %v", repo, err)
+		}
+	}
+	if requests.Load() != 0 {
+		t.Errorf("Expected no requests, got %d", requests.Load())
+	}
+}
@@ -0,0 +1,463 @@
+package fastembed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Registers the JPEG decoder for image.Decode.
+	_ "image/png"  // Registers the PNG decoder for image.Decode.
+	"math"
+	"os"
+	"path/filepath"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// The built-in image model, the vision encoder of CLIP ViT-B/32, to load with NewImageEmbedding.
+const CLIPViTB32Vision EmbeddingModel = "Qdrant/clip-ViT-B-32-vision"
+
+// Struct to interface with a CLIP-style vision encoder.
+// The images are preprocessed as described by the preprocessor_config.json of the model.
+type ImageEmbedding struct {
+	*onnxModel
+	preprocessor preprocessorConfig
+	normalize    bool
+}
+
+// Image preprocessing steps, as read from a Hugging Face preprocessor_config.json.
+type preprocessorConfig struct {
+	DoResize      bool            `json:"do_resize"`
+	Size          json.RawMessage `json:"size"`
+	Resample      int             `json:"resample"`
+	DoCenterCrop  bool            `json:"do_center_crop"`
+	CropSize      json.RawMessage `json:"crop_size"`
+	DoRescale     *bool           `json:"do_rescale"`
+	RescaleFactor float64         `json:"rescale_factor"`
+	DoNormalize   bool            `json:"do_normalize"`
+	ImageMean     []float32       `json:"image_mean"`
+	ImageStd      []float32       `json:"image_std"`
+
+	// Parsed from Size and CropSize.
+	shortestEdge int
+	resizeWidth  int
+	resizeHeight int
+	cropWidth    int
+	cropHeight   int
+}
+
+// The PIL resampling filter for bilinear interpolation, as used in preprocessor_config.json.
+const resampleBilinear = 2
+
+// Function to initialize CLIPViTB32Vision, or an image model registered with RegisterModel
+// The Dim of the model descriptor is the size of the image embeddings.
+// Pooling and MaxLength in the options are ignored.
+func NewImageEmbedding(options *InitOptions) (*ImageEmbedding, error) {
+	// The default model of withDefaultOptions is a text one.
+	if options == nil || options.Model == "" {
+		return nil, errors.New("an image model is required")
+	}
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	descriptor, err := getModelDescriptor(options.Model)
+	if err != nil {
+		return nil, err
+	}
+	// Set before retrieving the model, for the sources to know which files an image model needs.
+	if len(descriptor.InputNames) == 0 {
+		descriptor.InputNames = []string{pixelValuesName}
+	}
+
+	modelPath, err := retrieveModel(descriptor, options)
+	if err != nil {
+		return nil, err
+	}
+	return loadImageEmbedding(modelPath, descriptor, options)
+}
+
+// Function to initialize an image model from a local directory holding the ONNX and preprocessor_config.json files.
+// The descriptor does not need to be registered, options.Model and options.CacheDir are ignored.
+func NewImageEmbeddingFromDir(dir string, descriptor ModelDescriptor, options *InitOptions) (*ImageEmbedding, error) {
+	descriptor = descriptor.withDefaults()
+	if err := descriptor.validate(); err != nil {
+		return nil, err
+	}
+
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	options.Model = descriptor.Model
+	return loadImageEmbedding(dir, descriptor, options)
+}
+
+// Private function to load the files of an image model.
+func loadImageEmbedding(modelPath string, descriptor ModelDescriptor, options *InitOptions) (*ImageEmbedding, error) {
+	preprocessor, err := loadPreprocessorConfig(filepath.Join(modelPath, descriptor.PreprocessorConfigFile))
+	if err != nil {
+		return nil, err
+	}
+
+	spec := graphSpec{inputs: []string{pixelValuesName}, inputType: ort.TensorElementDataTypeFloat, outputs: []string{"image_embeds"}, ranks: []int{2}}
+	model, err := loadONNXSession(modelPath, descriptor, options, spec)
+	if err != nil {
+		return nil, err
+	}
+	return &ImageEmbedding{
+		onnxModel:    model,
+		preprocessor: preprocessor,
+		normalize:    *options.Normalize,
+	}, nil
+}
+
+// Function to release the model session when it is no longer needed.
+// Calling Close more than once is a no-op.
+func (e *ImageEmbedding) Close() error {
+	return e.close()
+}
+
+// Function to embed a batch of images
+// The batchSize parameter controls the number of images to embed in a single batch
+// The batches are processed in parallel, by at most InitOptions.MaxConcurrentBatches workers
+// Default batch size is 256.
+func (e *ImageEmbedding) Embed(images []image.Image, batchSize int) ([]([]float32), error) {
+	return e.EmbedContext(context.Background(), images, batchSize)
+}
+
+// Function to embed a batch of images, stopping early when the context is done.
+// See FlagEmbedding.EmbedContext for the cancellation semantics.
+func (e *ImageEmbedding) EmbedContext(ctx context.Context, images []image.Image, batchSize int) ([]([]float32), error) {
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	embeddings := make([]([]float32), len(images))
+	err := e.runBatches(ctx, len(images), batchSize, func(start, end int) error {
+		batchOut, err := e.onnxEmbed(images[start:end])
+		if err != nil {
+			return err
+		}
+		copy(embeddings[start:end], batchOut)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// Function to embed a batch of JPEG or PNG image files.
+func (e *ImageEmbedding) EmbedFiles(paths []string, batchSize int) ([]([]float32), error) {
+	images := make([]image.Image, len(paths))
+	for i, path := range paths {
+		img, err := decodeImageFile(path)
+		if err != nil {
+			return nil, err
+		}
+		images[i] = img
+	}
+	return e.Embed(images, batchSize)
+}
+
+// Private function to embed a batch of images.
+func (e *ImageEmbedding) onnxEmbed(images []image.Image) ([]([]float32), error) {
+	width, height := e.preprocessor.outputSize()
+	pixelValues := make([]float32, 0, len(images)*3*width*height)
+	for _, img := range images {
+		pixels, err := e.preprocessor.preprocess(img)
+		if err != nil {
+			return nil, err
+		}
+		pixelValues = append(pixelValues, pixels...)
+	}
+
+	inputTensor, err := ort.NewTensor(ort.NewShape(int64(len(images)), 3, int64(height), int64(width)), pixelValues)
+	if err != nil {
+		return nil, err
+	}
+	defer inputTensor.Destroy()
+
+	dim := e.descriptor.Dim
+	data, err := e.runTensors([]ort.ArbitraryTensor{inputTensor}, ort.NewShape(int64(len(images)), int64(dim)))
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([]([]float32), len(images))
+	for i := range embeddings {
+		embeddings[i] = append([]float32(nil), data[i*dim:(i+1)*dim]...)
+		if e.normalize {
+			embeddings[i] = normalize(embeddings[i])
+		}
+	}
+	return embeddings, nil
+}
+
+// Private function to decode a JPEG or PNG file.
+func decodeImageFile(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// Private function to read a preprocessor_config.json file.
+func loadPreprocessorConfig(path string) (preprocessorConfig, error) {
+	configData, err := os.ReadFile(path)
+	if err != nil {
+		return preprocessorConfig{}, err
+	}
+
+	var config preprocessorConfig
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return preprocessorConfig{}, err
+	}
+	if err := config.parseSizes(); err != nil {
+		return preprocessorConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if config.DoRescale == nil {
+		doRescale := true
+		config.DoRescale = &doRescale
+	}
+	if config.RescaleFactor == 0 {
+		config.RescaleFactor = 1.0 / 255
+	}
+	if config.DoNormalize && (len(config.ImageMean) != 3 || len(config.ImageStd) != 3) {
+		return preprocessorConfig{}, fmt.Errorf("%s: image_mean and image_std must have 3 values", path)
+	}
+	return config, nil
+}
+
+// Private function to parse the sizes, given either as a number or as an object.
+// "size" is either the shortest edge or an exact {"height", "width"}, "crop_size" is a square side or a {"height", "width"}.
+func (c *preprocessorConfig) parseSizes() error {
+	var sizes struct {
+		ShortestEdge int `json:"shortest_edge"`
+		Height       int `json:"height"`
+		Width        int `json:"width"`
+	}
+
+	if c.DoResize {
+		if err := json.Unmarshal(c.Size, &c.shortestEdge); err != nil {
+			if err := json.Unmarshal(c.Size, &sizes); err != nil {
+				return fmt.Errorf("invalid size: %w", err)
+			}
+			c.shortestEdge, c.resizeWidth, c.resizeHeight = sizes.ShortestEdge, sizes.Width, sizes.Height
+		}
+		if c.shortestEdge <= 0 && (c.resizeWidth <= 0 || c.resizeHeight <= 0) {
+			return errors.New("size must be positive")
+		}
+	}
+
+	if c.DoCenterCrop {
+		if err := json.Unmarshal(c.CropSize, &c.cropWidth); err == nil {
+			c.cropHeight = c.cropWidth
+		} else {
+			if err := json.Unmarshal(c.CropSize, &sizes); err != nil {
+				return fmt.Errorf("invalid crop_size: %w", err)
+			}
+			c.cropWidth, c.cropHeight = sizes.Width, sizes.Height
+		}
+		if c.cropWidth <= 0 || c.cropHeight <= 0 {
+			return errors.New("crop_size must be positive")
+		}
+	}
+
+	if !c.DoCenterCrop && (!c.DoResize || c.shortestEdge > 0) {
+		return errors.New("the output size must be fixed by crop_size or an exact size")
+	}
+	return nil
+}
+
+// Private function to return the size of the preprocessed images.
+func (c *preprocessorConfig) outputSize() (int, int) {
+	if c.DoCenterCrop {
+		return c.cropWidth, c.cropHeight
+	}
+	return c.resizeWidth, c.resizeHeight
+}
+
+// Private function to turn an image into normalized pixel values, in channel, height, width order.
+func (c *preprocessorConfig) preprocess(img image.Image) ([]float32, error) {
+	pixels, width, height := toRGB(img)
+	if width == 0 || height == 0 {
+		return nil, errors.New("empty image")
+	}
+
+	if c.DoResize {
+		newWidth, newHeight := c.resizeWidth, c.resizeHeight
+		if c.shortestEdge > 0 {
+			newWidth, newHeight = shortestEdgeSize(width, height, c.shortestEdge)
+		}
+		pixels = resize(pixels, width, height, newWidth, newHeight, c.Resample)
+		width, height = newWidth, newHeight
+	}
+
+	if c.DoCenterCrop {
+		pixels = centerCrop(pixels, width, height, c.cropWidth, c.cropHeight)
+		width, height = c.cropWidth, c.cropHeight
+	}
+
+	values := make([]float32, 3*width*height)
+	for channel := 0; channel < 3; channel++ {
+		for i := 0; i < width*height; i++ {
+			value := pixels[i*3+channel]
+			if *c.DoRescale {
+				value *= float32(c.RescaleFactor)
+			}
+			if c.DoNormalize {
+				value = (value - c.ImageMean[channel]) / c.ImageStd[channel]
+			}
+			values[channel*width*height+i] = value
+		}
+	}
+	return values, nil
+}
+
+// Private function to return the RGB values of an image, in height, width, channel order, from 0 to 255.
+func toRGB(img image.Image) ([]float32, int, int) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	pixels := make([]float32, 0, width*height*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Alpha is dropped, as done by PIL when converting to RGB.
+			// RGBA returns colors premultiplied by alpha, the non-premultiplied ones are kept instead.
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, float32(c.R), float32(c.G), float32(c.B))
+		}
+	}
+	return pixels, width, height
+}
+
+// Private function to compute the size of an image resized so that its shortest edge is the given size.
+// The longest edge is scaled in proportion and truncated, as done by the Hugging Face image processors.
+func shortestEdgeSize(width, height, size int) (int, int) {
+	if width <= height {
+		return size, int(float64(size) * float64(height) / float64(width))
+	}
+	return int(float64(size) * float64(width) / float64(height)), size
+}
+
+// Private function to resize RGB pixels, with a bicubic or bilinear filter depending on the PIL resample value.
+// Like PIL, the filter is widened when downscaling to anti-alias the result, and the values are rounded to 8 bits.
+func resize(pixels []float32, width, height, newWidth, newHeight, resample int) []float32 {
+	filter, support := bicubicFilter, 2.0
+	if resample == resampleBilinear {
+		filter, support = bilinearFilter, 1.0
+	}
+
+	// Resize the rows, then the columns.
+	horizontal := resampleAxis(pixels, width, height, newWidth, filter, support, true)
+	return resampleAxis(horizontal, newWidth, height, newHeight, filter, support, false)
+}
+
+// Private function to resample the pixels along one axis, the horizontal one if horizontal is true.
+func resampleAxis(pixels []float32, width, height, newSize int, filter func(float64) float64, support float64, horizontal bool) []float32 {
+	inSize, outWidth, outHeight := height, width, newSize
+	if horizontal {
+		inSize, outWidth, outHeight = width, newSize, height
+	}
+
+	scale := float64(inSize) / float64(newSize)
+	filterScale := max(scale, 1.0)
+	radius := support * filterScale
+
+	resized := make([]float32, outWidth*outHeight*3)
+	for i := 0; i < newSize; i++ {
+		center := (float64(i) + 0.5) * scale
+		first := max(int(center-radius+0.5), 0)
+		last := min(int(center+radius+0.5), inSize)
+
+		weights := make([]float64, last-first)
+		total := 0.0
+		for j := range weights {
+			weights[j] = filter((float64(first+j) - center + 0.5) / filterScale)
+			total += weights[j]
+		}
+
+		lines := outHeight
+		if !horizontal {
+			lines = outWidth
+		}
+		for line := 0; line < lines; line++ {
+			for channel := 0; channel < 3; channel++ {
+				sum := 0.0
+				for j, weight := range weights {
+					index := (line*width + first + j) * 3
+					if !horizontal {
+						index = ((first+j)*width + line) * 3
+					}
+					sum += weight * float64(pixels[index+channel])
+				}
+				if total != 0 {
+					sum /= total
+				}
+
+				index := (line*outWidth + i) * 3
+				if !horizontal {
+					index = (i*outWidth + line) * 3
+				}
+				resized[index+channel] = float32(min(max(math.Round(sum), 0), 255))
+			}
+		}
+	}
+	return resized
+}
+
+// Private function implementing the bicubic convolution kernel used by PIL, with a = -0.5.
+func bicubicFilter(x float64) float64 {
+	const a = -0.5
+	x = math.Abs(x)
+	switch {
+	case x < 1:
+		return ((a+2)*x-(a+3))*x*x + 1
+	case x < 2:
+		return (((x-5)*x+8)*x - 4) * a
+	default:
+		return 0
+	}
+}
+
+// Private function implementing the triangle kernel of bilinear interpolation.
+func bilinearFilter(x float64) float64 {
+	x = math.Abs(x)
+	if x < 1 {
+		return 1 - x
+	}
+	return 0
+}
+
+// Private function to crop the center of RGB pixels, padding with zeros when the image is smaller than the crop.
+func centerCrop(pixels []float32, width, height, cropWidth, cropHeight int) []float32 {
+	top := (height - cropHeight) / 2
+	left := (width - cropWidth) / 2
+
+	cropped := make([]float32, cropWidth*cropHeight*3)
+	for y := 0; y < cropHeight; y++ {
+		sourceY := top + y
+		if sourceY < 0 || sourceY >= height {
+			continue
+		}
+		for x := 0; x < cropWidth; x++ {
+			sourceX := left + x
+			if sourceX < 0 || sourceX >= width {
+				continue
+			}
+			copy(cropped[(y*cropWidth+x)*3:(y*cropWidth+x+1)*3], pixels[(sourceY*width+sourceX)*3:(sourceY*width+sourceX+1)*3])
+		}
+	}
+	return cropped
+}
@@ -0,0 +1,194 @@
+package fastembed_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+// Writes a preprocessor_config.json file with the given content and returns its path.
+func writePreprocessorConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "preprocessor_config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPreprocessImage(t *testing.T) {
+	configPath := writePreprocessorConfig(t, `{
+		"do_resize": true,
+		"size": {"shortest_edge": 2},
+		"resample": 3,
+		"do_center_crop": true,
+		"crop_size": 2,
+		"do_normalize": true,
+		"image_mean": [0.5, 0.5, 0.5],
+		"image_std": [0.5, 0.5, 0.5]
+	}`)
+
+	// A uniform image keeps its color through the resize and the crop.
+	img := image.NewRGBA(image.Rect(0, 0, 4, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.RGBA{R: 255, G: 0, B: 51, A: 255})
+		}
+	}
+
+	values, err := fastembed.PreprocessImage(configPath, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 3*2*2 {
+		t.Fatalf("Expected %d values, got %d", 3*2*2, len(values))
+	}
+
+	// The values are in channel, height, width order.
+	expected := []float32{1, -1, -0.6}
+	for i, v := range values {
+		if math.Abs(float64(v-expected[i/4])) > 1e-6 {
+			t.Errorf("Expected %f at index %d, got %f", expected[i/4], i, v)
+		}
+	}
+}
+
+func TestPreprocessImageCenterCrop(t *testing.T) {
+	configPath := writePreprocessorConfig(t, `{
+		"do_resize": false,
+		"do_center_crop": true,
+		"crop_size": {"height": 1, "width": 1},
+		"do_rescale": false
+	}`)
+
+	img := image.NewGray(image.Rect(0, 0, 3, 3))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 10)
+	}
+
+	values, err := fastembed.PreprocessImage(configPath, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		if v != 40 {
+			t.Errorf("Expected the center pixel value 40 at index %d, got %f", i, v)
+		}
+	}
+}
+
+func TestPreprocessImageTransparentPixel(t *testing.T) {
+	configPath := writePreprocessorConfig(t, `{
+		"do_resize": false,
+		"do_center_crop": true,
+		"crop_size": 1,
+		"do_rescale": false
+	}`)
+
+	// The color of a transparent pixel is kept when alpha is dropped, not premultiplied to black.
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 200, G: 100, B: 50, A: 0})
+
+	values, err := fastembed.PreprocessImage(configPath, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float32{200, 100, 50}
+	for i, v := range values {
+		if v != expected[i] {
+			t.Errorf("Expected %f at index %d, got %f", expected[i], i, v)
+		}
+	}
+}
+
+func TestPreprocessImageInvalidConfig(t *testing.T) {
+	for name, content := range map[string]string{
+		"no output size":  `{"do_resize": true, "size": {"shortest_edge": 224}}`,
+		"bad crop size":   `{"do_center_crop": true, "crop_size": 0}`,
+		"missing std":     `{"do_center_crop": true, "crop_size": 2, "do_normalize": true, "image_mean": [0.5, 0.5, 0.5]}`,
+		"not json object": `[]`,
+	} {
+		if _, err := fastembed.PreprocessImage(writePreprocessorConfig(t, content), image.NewGray(image.Rect(0, 0, 2, 2))); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// Returns a uniform image of the given color.
+func uniformImage(c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestImageEmbedding(t *testing.T) {
+	clip, err := fastembed.NewImageEmbedding(&fastembed.InitOptions{Model: fastembed.CLIPViTB32Vision})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer clip.Close()
+
+	red := uniformImage(color.RGBA{R: 255, A: 255})
+	blue := uniformImage(color.RGBA{B: 255, A: 255})
+	embeddings, err := clip.Embed([]image.Image{red, blue}, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(embeddings) != 2 {
+		t.Fatalf("Expected 2 embeddings, got %d", len(embeddings))
+	}
+	for i, embedding := range embeddings {
+		if len(embedding) != 512 {
+			t.Fatalf("Expected embeddings of length 512, got %d", len(embedding))
+		}
+		norm := 0.0
+		for _, v := range embedding {
+			norm += float64(v * v)
+		}
+		if math.Abs(norm-1) > 1e-4 {
+			t.Errorf("Expected embedding %d to be normalized, got a squared norm of %f", i, norm)
+		}
+	}
+	same := true
+	for i := range embeddings[0] {
+		if embeddings[0][i] != embeddings[1][i] {
+			same = false
+			break
+		}
+	}
+	if same {
+		t.Error("Expected different images to have different embeddings")
+	}
+
+	// A decoded file is embedded as the image itself, whatever the batch size.
+	path := filepath.Join(t.TempDir(), "red.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, red); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fromFile, err := clip.EmbedFiles([]string{path}, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, v := range embeddings[0] {
+		if math.Abs(float64(fromFile[0][i]-v)) > 1e-5 {
+			t.Fatalf("Element %d mismatch: expected %.6f, got %.6f", i, v, fromFile[0][i])
+		}
+	}
+}
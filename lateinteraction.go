@@ -0,0 +1,283 @@
+package fastembed
+
+import (
+	"context"
+	"errors"
+)
+
+// The built-in late-interaction model, to load with NewLateInteractionTextEmbedding.
+const ColBERTV2 EmbeddingModel = "colbert-ir/colbertv2.0"
+
+// The token used to pad queries up to ModelDescriptor.QueryLength, as done by ColBERT.
+const queryAugmentationToken = "[MASK]"
+
+// Struct to interface with a late-interaction, ColBERT-style, model.
+// Every input is embedded into one L2 normalized vector per token, to be scored with MaxSim.
+type LateInteractionTextEmbedding struct {
+	*onnxModel
+	queryMarkerID    int64
+	documentMarkerID int64
+	maskID           int64
+}
+
+// Function to initialize ColBERTV2, or a late-interaction model registered with RegisterModel
+// The QueryMarker, DocumentMarker and QueryLength of the model descriptor set the ColBERT query and document handling.
+// Pooling and Normalize in the options are ignored.
+func NewLateInteractionTextEmbedding(options *InitOptions) (*LateInteractionTextEmbedding, error) {
+	// The default model of withDefaultOptions is a single vector one.
+	if options == nil || options.Model == "" {
+		return nil, errors.New("a late-interaction model is required")
+	}
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	descriptor, err := getModelDescriptor(options.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	modelPath, err := retrieveModel(descriptor, options)
+	if err != nil {
+		return nil, err
+	}
+	return loadLateInteractionTextEmbedding(modelPath, descriptor, options)
+}
+
+// Function to initialize a late-interaction model from a local directory holding the ONNX and tokenizer files.
+// The descriptor does not need to be registered, options.Model and options.CacheDir are ignored.
+func NewLateInteractionTextEmbeddingFromDir(dir string, descriptor ModelDescriptor, options *InitOptions) (*LateInteractionTextEmbedding, error) {
+	descriptor = descriptor.withDefaults()
+	if err := descriptor.validate(); err != nil {
+		return nil, err
+	}
+
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	options.Model = descriptor.Model
+	return loadLateInteractionTextEmbedding(dir, descriptor, options)
+}
+
+// Private function to load the files of a late-interaction model and look up its marker tokens.
+func loadLateInteractionTextEmbedding(modelPath string, descriptor ModelDescriptor, options *InitOptions) (*LateInteractionTextEmbedding, error) {
+	// A marker token is inserted after the first token, keep room for it.
+	reservedTokens := 0
+	if descriptor.QueryMarker != "" || descriptor.DocumentMarker != "" {
+		reservedTokens = 1
+	}
+
+	model, err := loadONNXModel(modelPath, descriptor, options, textGraphSpec([]int{3}, "last_hidden_state"), reservedTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &LateInteractionTextEmbedding{onnxModel: model, queryMarkerID: -1, documentMarkerID: -1, maskID: -1}
+	if err := l.lookupTokens(); err != nil {
+		model.close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Private function to look up the ids of the marker and query augmentation tokens, -1 standing for none.
+func (l *LateInteractionTextEmbedding) lookupTokens() error {
+	var err error
+	if l.descriptor.QueryMarker != "" {
+		if l.queryMarkerID, err = l.tokenID(l.descriptor.QueryMarker); err != nil {
+			return err
+		}
+	}
+	if l.descriptor.DocumentMarker != "" {
+		if l.documentMarkerID, err = l.tokenID(l.descriptor.DocumentMarker); err != nil {
+			return err
+		}
+	}
+	if l.descriptor.QueryLength > 0 {
+		if l.maskID, err = l.tokenID(queryAugmentationToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Function to release the model session when it is no longer needed.
+// Calling Close more than once is a no-op.
+func (l *LateInteractionTextEmbedding) Close() error {
+	return l.close()
+}
+
+// Function to embed a batch of documents into one vector per token
+// The batchSize parameter controls the number of inputs to embed in a single batch
+// The batches are processed in parallel, by at most InitOptions.MaxConcurrentBatches workers
+// Default batch size is 256.
+func (l *LateInteractionTextEmbedding) Embed(input []string, batchSize int) ([]([][]float32), error) {
+	return l.EmbedContext(context.Background(), input, batchSize)
+}
+
+// Function to embed a batch of documents into one vector per token, stopping early when the context is done.
+// See FlagEmbedding.EmbedContext for the cancellation semantics.
+func (l *LateInteractionTextEmbedding) EmbedContext(ctx context.Context, input []string, batchSize int) ([]([][]float32), error) {
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	processedInput := make([]string, len(input))
+	for i, v := range input {
+		processedInput[i] = l.descriptor.PassagePrefix + v
+	}
+
+	embeddings := make([]([][]float32), len(input))
+	err := l.runBatches(ctx, len(input), batchSize, func(start, end int) error {
+		batchOut, err := l.onnxEmbed(processedInput[start:end], false)
+		if err != nil {
+			return err
+		}
+		copy(embeddings[start:end], batchOut)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// Function to embed a query into one vector per token
+// The query is padded with "[MASK]" tokens up to the QueryLength of the model, if any.
+func (l *LateInteractionTextEmbedding) QueryEmbed(input string) ([][]float32, error) {
+	return l.QueryEmbedContext(context.Background(), input)
+}
+
+// Function to embed a query into one vector per token, unless the context is already done.
+func (l *LateInteractionTextEmbedding) QueryEmbedContext(ctx context.Context, input string) ([][]float32, error) {
+	var embeddings []([][]float32)
+	err := l.runBatches(ctx, 1, 1, func(_, _ int) error {
+		var err error
+		embeddings, err = l.onnxEmbed([]string{l.descriptor.QueryPrefix + input}, true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// Private function to embed a batch of queries or documents.
+func (l *LateInteractionTextEmbedding) onnxEmbed(input []string, isQuery bool) ([]([][]float32), error) {
+	batch, err := l.encode(input)
+	if err != nil {
+		return nil, err
+	}
+
+	markerID := l.documentMarkerID
+	if isQuery {
+		markerID = l.queryMarkerID
+	}
+	if markerID >= 0 {
+		batch = insertMarker(batch, markerID)
+	}
+	if isQuery && l.descriptor.QueryLength > 0 {
+		batch = augmentQuery(batch, l.maskID, l.descriptor.QueryLength)
+	}
+
+	shape := batch.tokenShape(l.descriptor.Dim)
+	data, err := l.run(batch, shape)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTokenEmbeddings(data, shape, batch.inputMask), nil
+}
+
+// Function to compute the late-interaction relevance score of a document to a query
+// The sum, over the query vectors, of their maximum dot product with the document vectors.
+func MaxSim(query, document [][]float32) float32 {
+	score := float32(0.0)
+	for _, q := range query {
+		best := float32(0.0)
+		for i, d := range document {
+			similarity := dot(q, d)
+			if i == 0 || similarity > best {
+				best = similarity
+			}
+		}
+		score += best
+	}
+	return score
+}
+
+// Private function to compute the dot product of two vectors of the same length.
+func dot(a, b []float32) float32 {
+	sum := float32(0.0)
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// Private function to return the normalized vectors of the unmasked tokens from a flattened array with the given dimensions.
+func getTokenEmbeddings(data []float32, dimensions []int64, mask []int64) []([][]float32) {
+	x, y, z := dimensions[0], dimensions[1], dimensions[2]
+	embeddings := make([]([][]float32), x)
+	var i, j int64
+	for i = 0; i < x; i++ {
+		embeddings[i] = make([][]float32, 0, y)
+		for j = 0; j < y; j++ {
+			if mask[i*y+j] == 0 {
+				continue
+			}
+			startIndex := (i*y + j) * z
+			embeddings[i] = append(embeddings[i], normalize(data[startIndex:startIndex+z]))
+		}
+	}
+	return embeddings
+}
+
+// Private function to insert a marker token after the first token of every input of the batch.
+func insertMarker(batch *encodedBatch, markerID int64) *encodedBatch {
+	seqLen := batch.seqLen + 1
+	marked := &encodedBatch{
+		encodings:    batch.encodings,
+		inputIds:     make([]int64, 0, batch.size*seqLen),
+		inputMask:    make([]int64, 0, batch.size*seqLen),
+		inputTypeIds: make([]int64, 0, batch.size*seqLen),
+		size:         batch.size,
+		seqLen:       seqLen,
+	}
+	for i := 0; i < batch.size; i++ {
+		start, end := i*batch.seqLen, (i+1)*batch.seqLen
+		marked.inputIds = append(append(append(marked.inputIds, batch.inputIds[start]), markerID), batch.inputIds[start+1:end]...)
+		marked.inputMask = append(append(append(marked.inputMask, batch.inputMask[start]), 1), batch.inputMask[start+1:end]...)
+		marked.inputTypeIds = append(append(append(marked.inputTypeIds, batch.inputTypeIds[start]), 0), batch.inputTypeIds[start+1:end]...)
+	}
+	return marked
+}
+
+// Private function to replace the padding of the batch with attended mask tokens, up to at least length tokens.
+// This is the query augmentation of ColBERT.
+func augmentQuery(batch *encodedBatch, maskID int64, length int) *encodedBatch {
+	seqLen := max(batch.seqLen, length)
+	augmented := &encodedBatch{
+		encodings:    batch.encodings,
+		inputIds:     make([]int64, 0, batch.size*seqLen),
+		inputMask:    make([]int64, 0, batch.size*seqLen),
+		inputTypeIds: make([]int64, 0, batch.size*seqLen),
+		size:         batch.size,
+		seqLen:       seqLen,
+	}
+	for i := 0; i < batch.size; i++ {
+		for j := 0; j < seqLen; j++ {
+			index := i*batch.seqLen + j
+			if j < batch.seqLen && batch.inputMask[index] != 0 {
+				augmented.inputIds = append(augmented.inputIds, batch.inputIds[index])
+				augmented.inputTypeIds = append(augmented.inputTypeIds, batch.inputTypeIds[index])
+			} else {
+				augmented.inputIds = append(augmented.inputIds, maskID)
+				augmented.inputTypeIds = append(augmented.inputTypeIds, 0)
+			}
+			augmented.inputMask = append(augmented.inputMask, 1)
+		}
+	}
+	return augmented
+}
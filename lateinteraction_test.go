@@ -0,0 +1,124 @@
+package fastembed_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestMaxSim(t *testing.T) {
+	query := [][]float32{{1, 0}, {0, 1}}
+	document := [][]float32{{0.5, 0.5}, {0.9, -0.1}, {-1, 0.2}}
+
+	// 0.9 for the first query vector, 0.5 for the second.
+	if score := fastembed.MaxSim(query, document); math.Abs(float64(score)-1.4) > 1e-6 {
+		t.Errorf("Expected 1.4, got %.6f", score)
+	}
+}
+
+func TestGetTokenEmbeddings(t *testing.T) {
+	// Two inputs of two tokens of dimension 2, the second input having one padding token.
+	data := []float32{
+		3, 4, 0, 2,
+		-1, 0, 7, 7,
+	}
+	mask := []int64{1, 1, 1, 0}
+
+	result := fastembed.GetTokenEmbeddings(data, []int64{2, 2, 2}, mask)
+	expected := [][][]float32{
+		{{0.6, 0.8}, {0, 1}},
+		{{-1, 0}},
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d inputs, got %d", len(expected), len(result))
+	}
+	for i := range expected {
+		if len(result[i]) != len(expected[i]) {
+			t.Fatalf("Expected %d vectors for input %d, got %d", len(expected[i]), i, len(result[i]))
+		}
+		for j := range expected[i] {
+			for k, v := range expected[i][j] {
+				if diff := result[i][j][k] - v; diff > 1e-6 || diff < -1e-6 {
+					t.Errorf("Element %d of vector %d of input %d: expected %.6f, got %.6f", k, j, i, v, result[i][j][k])
+				}
+			}
+		}
+	}
+}
+
+func TestMarkerRoomAfterModelMaxLength(t *testing.T) {
+	dir := filepath.Dir(writeWordLevelTokenizer(t, []string{"one"}))
+	configs := map[string]string{
+		"config.json":             `{"pad_token_id": 0}`,
+		"tokenizer_config.json":   `{"model_max_length": 8, "pad_token": "[UNK]"}`,
+		"special_tokens_map.json": `{}`,
+	}
+	for name, content := range configs {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	descriptor := fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: "marker-model", Dim: 2}}
+
+	// The marker token is reserved from the length capped by the model, so the inputs never exceed it.
+	testCases := []struct {
+		maxLength, reservedTokens, expected int
+	}{
+		{maxLength: 512, reservedTokens: 1, expected: 7},
+		{maxLength: 8, reservedTokens: 1, expected: 7},
+		{maxLength: 6, reservedTokens: 1, expected: 5},
+		{maxLength: 512, reservedTokens: 0, expected: 8},
+	}
+	for _, tc := range testCases {
+		length, err := fastembed.TruncationLength(dir, descriptor, tc.maxLength, tc.reservedTokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if length != tc.expected {
+			t.Errorf("Expected a length of %d for %d with %d reserved, got %d", tc.expected, tc.maxLength, tc.reservedTokens, length)
+		}
+	}
+
+	if _, err := fastembed.TruncationLength(dir, descriptor, 1, 1); err == nil {
+		t.Error("Expected an error without room for the input tokens")
+	}
+}
+
+func TestColBERTV2(t *testing.T) {
+	colbert, err := fastembed.NewLateInteractionTextEmbedding(&fastembed.InitOptions{Model: fastembed.ColBERTV2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer colbert.Close()
+
+	documents := []string{
+		"Bananas are yellow and rich in potassium.",
+		"Paris is the capital and largest city of France.",
+	}
+	documentVectors, err := colbert.Embed(documents, 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	query, err := colbert.QueryEmbed("What is the capital of France?")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The query is padded with [MASK] tokens up to the query length.
+	if len(query) != 32 {
+		t.Errorf("Expected 32 query vectors, got %d", len(query))
+	}
+	for _, vectors := range append(documentVectors, query) {
+		for _, vector := range vectors {
+			if len(vector) != 128 {
+				t.Fatalf("Expected vectors of length 128, got %d", len(vector))
+			}
+		}
+	}
+	if bananas, paris := fastembed.MaxSim(query, documentVectors[0]), fastembed.MaxSim(query, documentVectors[1]); paris <= bananas {
+		t.Errorf("Expected the capital to score higher than the bananas, got %.4f and %.4f", paris, bananas)
+	}
+}
@@ -0,0 +1,142 @@
+package fastembed
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// A lock file not refreshed for this long is left from a crashed process, and is removed.
+	staleLockAge = time.Minute
+	// How often the owner of a lock refreshes the lock file.
+	lockRefreshInterval = 10 * time.Second
+	// How often a process waiting for a lock checks the lock file.
+	lockPollInterval = 100 * time.Millisecond
+)
+
+// Error returned when a model is still being downloaded by another process after InitOptions.LockTimeout.
+var ErrLockTimeout = errors.New("timed out waiting for the model lock")
+
+// Struct holding a lock file, refreshed in the background until released.
+type fileLock struct {
+	path string
+	info fs.FileInfo
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Private function to take a lock shared across processes, waiting for at most timeout.
+// The lock is a file created exclusively, that other processes wait to be removed.
+// Its modification time is refreshed while the lock is held, so a lock file left by a crashed process is detected as stale.
+func acquireLock(path string, timeout time.Duration) (*fileLock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			// The owner is informational, for whoever finds the lock file.
+			hostname, _ := os.Hostname()
+			_, _ = fmt.Fprintf(file, "%d@%s\n", os.Getpid(), hostname)
+			info, err := file.Stat()
+			file.Close()
+			if err != nil {
+				_ = os.Remove(path)
+				return nil, err
+			}
+
+			lock := &fileLock{path: path, info: info, stop: make(chan struct{})}
+			lock.wg.Add(1)
+			go lock.refresh()
+			return lock, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Released in the meantime.
+			continue
+		case err != nil:
+			return nil, err
+		case time.Since(info.ModTime()) > staleLockAge:
+			if err := removeStaleLock(path, info); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			owner, _ := os.ReadFile(path)
+			return nil, fmt.Errorf("%w %s, held by %s", ErrLockTimeout, path, owner)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Private function to remove the lock file at path, found stale with the given info
+// Processes finding the lock stale at the same time may have taken it over since, so the file is first renamed
+// to a name unique to the caller, and removed only if it is the stale file. A fresh lock file is put back.
+func removeStaleLock(path string, stale fs.FileInfo) error {
+	renamed := fmt.Sprintf("%s.%d-%d.stale", path, os.Getpid(), rand.Int63())
+	if err := os.Rename(path, renamed); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Taken over by another process.
+			return nil
+		}
+		return err
+	}
+
+	info, err := os.Stat(renamed)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, stale) || time.Since(info.ModTime()) <= staleLockAge {
+		// The lock was taken over, or refreshed, since found stale.
+		// A hard link puts it back without replacing a lock file created in the meantime.
+		if err := os.Link(renamed, path); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return os.Remove(renamed)
+}
+
+// Private function to keep the lock file fresh until the lock is released.
+func (l *fileLock) refresh() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			_ = os.Chtimes(l.path, now, now)
+		}
+	}
+}
+
+// Private function to release the lock.
+// A lock file taken over by another process, having found it stale, is left to that process.
+func (l *fileLock) release() error {
+	close(l.stop)
+	l.wg.Wait()
+	info, err := os.Stat(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(info, l.info) {
+		return nil
+	}
+	return os.Remove(l.path)
+}
@@ -0,0 +1,268 @@
+package fastembed_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestAcquireLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), ".test-model.lock")
+	releaseFirst, err := fastembed.AcquireLock(lockPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fastembed.AcquireLock(lockPath, 200*time.Millisecond); !errors.Is(err, fastembed.ErrLockTimeout) {
+		t.Fatalf("Expected a lock timeout, got %v", err)
+	}
+
+	// A waiting process gets the lock once released.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = releaseFirst()
+	}()
+	release, err := fastembed.AcquireLock(lockPath, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	// A lock file left by a crashed process.
+	lockPath := filepath.Join(t.TempDir(), ".test-model.lock")
+	if err := os.WriteFile(lockPath, []byte("1@crashed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lastHour := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, lastHour, lastHour); err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := fastembed.AcquireLock(lockPath, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be taken over, got %v", err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetrieveModelConcurrently(t *testing.T) {
+	archive, _ := buildModelArchive(t, string(fastembed.BGESmallENV15))
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Slow enough for the other downloads to start waiting.
+		time.Sleep(200 * time.Millisecond)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(archive))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			showDownloadProgress := false
+			_, err := fastembed.RetrieveModel(fastembed.BGESmallENV15, &fastembed.InitOptions{
+				CacheDir:             cacheDir,
+				ShowDownloadProgress: &showDownloadProgress,
+				HTTPClient:           server.Client(),
+				BaseURL:              server.URL,
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected a single download, got %d", requests.Load())
+	}
+	assertCacheEntries(t, cacheDir, string(fastembed.BGESmallENV15))
+}
+
+func TestRemoveStaleLock(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), ".test-model.lock")
+	if err := os.WriteFile(lockPath, []byte("1@crashed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lastHour := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, lastHour, lastHour); err != nil {
+		t.Fatal(err)
+	}
+	stale, err := os.Stat(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process took the stale lock over after it was found stale.
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath, []byte("2@fresh"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fastembed.RemoveStaleLock(lockPath, stale); err != nil {
+		t.Fatal(err)
+	}
+	owner, err := os.ReadFile(lockPath)
+	if err != nil || string(owner) != "2@fresh" {
+		t.Fatalf("Expected the fresh lock to be kept, got %q, %v", owner, err)
+	}
+	assertCacheEntries(t, filepath.Dir(lockPath), ".test-model.lock")
+
+	// Removed when it is the stale lock file.
+	if err := os.Chtimes(lockPath, lastHour, lastHour); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err = os.Stat(lockPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := fastembed.RemoveStaleLock(lockPath, stale); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheEntries(t, filepath.Dir(lockPath))
+}
+
+func TestAcquireStaleLockConcurrently(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), ".test-model.lock")
+	if err := os.WriteFile(lockPath, []byte("1@crashed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lastHour := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, lastHour, lastHour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The processes finding the lock stale take it over one at a time.
+	var holders atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := fastembed.AcquireLock(lockPath, 10*time.Second)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if n := holders.Add(1); n != 1 {
+				errs <- fmt.Errorf("%d holders of the lock", n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			holders.Add(-1)
+			errs <- release()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertCacheEntries(t, filepath.Dir(lockPath))
+}
+
+// A source writing the files of a text model into the cache directory, in a directory named after the model.
+// It looks up the cache only in offline mode, as the sources do.
+type writingSource struct {
+	downloads *atomic.Int32
+}
+
+func (s writingSource) Fetch(descriptor fastembed.ModelDescriptor, options *fastembed.InitOptions) (string, error) {
+	modelPath := filepath.Join(options.CacheDir, filepath.FromSlash(string(descriptor.Model)))
+	if _, err := os.Stat(modelPath); err == nil {
+		return modelPath, nil
+	}
+	if options.Offline {
+		return "", fastembed.ErrModelNotCached
+	}
+	s.downloads.Add(1)
+	if err := os.MkdirAll(modelPath, 0755); err != nil {
+		return "", err
+	}
+	return modelPath, os.WriteFile(filepath.Join(modelPath, "model_optimized.onnx"), []byte("onnx"), 0600)
+}
+
+func TestRetrieveModelLock(t *testing.T) {
+	// The lock file of a model named after its repository is not in a subdirectory.
+	model := fastembed.EmbeddingModel("lock-test-owner/lock-test-model")
+	if err := fastembed.RegisterModel(fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: model, Dim: 4}}); err != nil {
+		t.Fatal(err)
+	}
+	var downloads atomic.Int32
+	cacheDir := t.TempDir()
+	options := &fastembed.InitOptions{CacheDir: cacheDir, Source: writingSource{downloads: &downloads}, LockTimeout: 200 * time.Millisecond}
+	lockPath := fastembed.ModelLockPath(cacheDir, model)
+	if filepath.Dir(lockPath) != cacheDir {
+		t.Errorf("Expected the lock file in %s, got %s", cacheDir, lockPath)
+	}
+
+	release, err := fastembed.AcquireLock(lockPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The download waits for the lock.
+	if _, err := fastembed.RetrieveModel(model, options); !errors.Is(err, fastembed.ErrLockTimeout) {
+		t.Fatalf("Expected a lock timeout, got %v", err)
+	}
+	if err := release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fastembed.RetrieveModel(model, options); err != nil {
+		t.Fatal(err)
+	}
+	assertCacheEntries(t, cacheDir, "lock-test-owner")
+
+	// A cached model is used without the lock.
+	release, err = fastembed.AcquireLock(lockPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if _, err := fastembed.RetrieveModel(model, options); err != nil {
+		t.Fatalf("Expected the cached model without waiting for the lock, got %v", err)
+	}
+	if downloads.Load() != 1 {
+		t.Errorf("Expected a single download, got %d", downloads.Load())
+	}
+
+	// Nothing is written to the cache directory for a local source.
+	missingCacheDir := filepath.Join(t.TempDir(), "cache")
+	localDir := t.TempDir()
+	writeModelFiles(t, localDir, textModelFiles...)
+	if _, err := fastembed.RetrieveModel(model, &fastembed.InitOptions{CacheDir: missingCacheDir, Source: fastembed.LocalSource{Dir: localDir}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(missingCacheDir); !os.IsNotExist(err) {
+		t.Errorf("Expected no cache directory, got %v", err)
+	}
+}
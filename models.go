@@ -0,0 +1,292 @@
+package fastembed
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Struct describing how to load and run an embedding model
+// ModelInfo: The name, dimension, description and pooling strategy of the model, the name and dimension are required
+// ModelFile: The ONNX file of the model, defaults to "model_optimized.onnx"
+// TokenizerFile: Defaults to "tokenizer.json"
+// ConfigFile: Defaults to "config.json"
+// TokenizerConfigFile: Defaults to "tokenizer_config.json"
+// SpecialTokensMapFile: Defaults to "special_tokens_map.json"
+// PreprocessorConfigFile: The image preprocessing configuration of image models, defaults to "preprocessor_config.json"
+// InputNames: The ONNX inputs fed to the model, "input_ids" and optionally "attention_mask" and "token_type_ids" for text models,
+// and "pixel_values" for image models, defaulting to the inputs declared by the ONNX model
+// OutputName: The ONNX output of the model, defaulting to the first one declared by the ONNX model among
+// "sentence_embedding", "last_hidden_state" and "token_embeddings" for dense text models, "sentence_embedding" being skipped
+// when InitOptions.Pooling is set, "last_hidden_state" for late-interaction models, "image_embeds" for image models
+// and "logits" for the others
+// QueryPrefix: The prefix added by QueryEmbed, none if empty
+// PassagePrefix: The prefix added by PassageEmbed, none if empty
+// URL: The URL of a .tar.gz archive holding a directory named after the model, none if empty
+// QueryMarker: The token inserted after the first token of queries by late-interaction models, such as "[unused0]" for ColBERT
+// DocumentMarker: The token inserted after the first token of documents by late-interaction models, such as "[unused1]" for ColBERT
+// QueryLength: The length up to which late-interaction models pad queries with "[MASK]" tokens, none if 0
+// ArchiveSHA256: The hex encoded SHA-256 digest of the archive at URL, checked while downloading, none if empty
+// FileSHA256: The hex encoded SHA-256 digests of model files, by path relative to the model directory with "/" separators,
+// checked after downloading and by VerifyModel
+// Source: Where the model is fetched from when InitOptions.Source is not set, defaults to GCSSource
+// NOTE:
+// A model without a URL must already be present in the cache directory.
+type ModelDescriptor struct {
+	ModelInfo
+	ModelFile              string
+	TokenizerFile          string
+	ConfigFile             string
+	TokenizerConfigFile    string
+	SpecialTokensMapFile   string
+	PreprocessorConfigFile string
+	InputNames             []string
+	OutputName             string
+	QueryPrefix            string
+	PassagePrefix          string
+	URL                    string
+	QueryMarker            string
+	DocumentMarker         string
+	QueryLength            int
+	ArchiveSHA256          string
+	FileSHA256             map[string]string
+	Source                 ModelSource
+}
+
+const (
+	inputIDsName      = "input_ids"
+	attentionMaskName = "attention_mask"
+	tokenTypeIDsName  = "token_type_ids"
+	pixelValuesName   = "pixel_values"
+)
+
+// The models registered with RegisterModel, on top of the ones listed by ListSupportedModels.
+var (
+	registryMu       sync.RWMutex
+	registeredModels = map[EmbeddingModel]ModelDescriptor{}
+)
+
+// Function to register a custom model, so it can be loaded by name with NewFlagEmbedding.
+// Returns an error if the descriptor is invalid or a model with the same name exists.
+func RegisterModel(descriptor ModelDescriptor) error {
+	descriptor = descriptor.withDefaults()
+	if err := descriptor.validate(); err != nil {
+		return err
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registeredModels[descriptor.Model]; ok {
+		return fmt.Errorf("model %s is already registered", descriptor.Model)
+	}
+	if _, err := getBuiltinModelInfo(descriptor.Model); err == nil {
+		return fmt.Errorf("model %s is already registered", descriptor.Model)
+	}
+	if _, ok := getBuiltinHubModel(descriptor.Model); ok {
+		return fmt.Errorf("model %s is already registered", descriptor.Model)
+	}
+	registeredModels[descriptor.Model] = descriptor
+	return nil
+}
+
+// Private function to return the information of the registered models, sorted by name.
+func registeredModelInfos() []ModelInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	infos := make([]ModelInfo, 0, len(registeredModels))
+	for _, descriptor := range registeredModels {
+		infos = append(infos, descriptor.ModelInfo)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Model < infos[j].Model
+	})
+	return infos
+}
+
+// Private function to get the descriptor of a built-in or registered model from the model name.
+func getModelDescriptor(model EmbeddingModel) (ModelDescriptor, error) {
+	registryMu.RLock()
+	descriptor, ok := registeredModels[model]
+	registryMu.RUnlock()
+	if ok {
+		return descriptor, nil
+	}
+	if descriptor, ok := getBuiltinHubModel(model); ok {
+		return descriptor.withBuiltinDigests().withDefaults(), nil
+	}
+
+	info, err := getBuiltinModelInfo(model)
+	if err != nil {
+		return ModelDescriptor{}, err
+	}
+	descriptor = ModelDescriptor{
+		ModelInfo:     info,
+		QueryPrefix:   "query: ",
+		PassagePrefix: "passage: ",
+		URL:           builtinModelURL(defaultBaseURL, model),
+	}
+	// XLM-RoBERTa models have no token type embeddings, so the ONNX model takes no token_type_ids input.
+	if model == MLE5Large {
+		descriptor.InputNames = []string{inputIDsName, attentionMaskName}
+	}
+	return descriptor.withBuiltinDigests().withDefaults(), nil
+}
+
+// Struct holding the SHA-256 digests of a released built-in model
+// archive: The hex encoded digest of the archive of a model fetched from GCSSource, none for the Hugging Face Hub models
+// files: The hex encoded digests of the model files, by path relative to the model directory with "/" separators
+type builtinDigest struct {
+	archive string
+	files   map[string]string
+}
+
+// The digests of the built-in models, by model name, computed from the released artifacts
+// A built-in model without digests is downloaded unchecked, the test of the digests lists the ones still missing.
+var builtinDigests = map[EmbeddingModel]builtinDigest{}
+
+// Private function to set the digests of a built-in model on its descriptor, if known.
+func (d ModelDescriptor) withBuiltinDigests() ModelDescriptor {
+	if digest, ok := builtinDigests[d.Model]; ok {
+		d.ArchiveSHA256 = digest.archive
+		d.FileSHA256 = digest.files
+	}
+	return d
+}
+
+// Private function to list the built-in models fetched from the Hugging Face Hub, named after their repository
+// Unlike the models listed by ListSupportedModels, they are loaded with the constructor of their model type.
+func builtinHubModels() []ModelDescriptor {
+	return []ModelDescriptor{
+		{
+			ModelInfo: ModelInfo{
+				Model:       BGERerankerBase,
+				Dim:         1,
+				Description: "Multilingual reranker, bge-reranker-base, for NewTextCrossEncoder",
+			},
+			ModelFile: "onnx/model.onnx",
+			Source:    HuggingFaceSource{Repo: string(BGERerankerBase)},
+		},
+		{
+			ModelInfo: ModelInfo{
+				Model:       MSMarcoMiniLML6V2,
+				Dim:         1,
+				Description: "Fast English reranker, ms-marco-MiniLM-L-6-v2, for NewTextCrossEncoder",
+			},
+			ModelFile: "onnx/model.onnx",
+			Source:    HuggingFaceSource{Repo: string(MSMarcoMiniLML6V2)},
+		},
+		{
+			ModelInfo: ModelInfo{
+				Model:       CLIPViTB32Vision,
+				Dim:         512,
+				Description: "Vision encoder of CLIP ViT-B/32, for NewImageEmbedding",
+			},
+			ModelFile:  "model.onnx",
+			InputNames: []string{pixelValuesName},
+			Source:     HuggingFaceSource{Repo: string(CLIPViTB32Vision)},
+		},
+		{
+			ModelInfo: ModelInfo{
+				Model:       SpladePPENV1,
+				Dim:         30522,
+				Description: "SPLADE++ English sparse model, for NewSparseTextEmbedding",
+				Pooling:     MaxPooling,
+			},
+			ModelFile: "model.onnx",
+			Source:    HuggingFaceSource{Repo: string(SpladePPENV1)},
+		},
+		{
+			ModelInfo: ModelInfo{
+				Model:       ColBERTV2,
+				Dim:         128,
+				Description: "English late-interaction model, ColBERTv2.0, for NewLateInteractionTextEmbedding",
+			},
+			ModelFile:      "model.onnx",
+			QueryMarker:    "[unused0]",
+			DocumentMarker: "[unused1]",
+			QueryLength:    32,
+			Source:         HuggingFaceSource{Repo: string(ColBERTV2)},
+		},
+	}
+}
+
+// Private function to get the descriptor of a built-in model of the Hugging Face Hub from the model name.
+func getBuiltinHubModel(model EmbeddingModel) (ModelDescriptor, bool) {
+	for _, descriptor := range builtinHubModels() {
+		if descriptor.Model == model {
+			return descriptor, true
+		}
+	}
+	return ModelDescriptor{}, false
+}
+
+// Private function to fill in the default file names.
+// The default input and output names depend on the model type and are set when loading the model.
+func (d ModelDescriptor) withDefaults() ModelDescriptor {
+	if d.ModelFile == "" {
+		d.ModelFile = "model_optimized.onnx"
+	}
+	if d.TokenizerFile == "" {
+		d.TokenizerFile = "tokenizer.json"
+	}
+	if d.ConfigFile == "" {
+		d.ConfigFile = "config.json"
+	}
+	if d.TokenizerConfigFile == "" {
+		d.TokenizerConfigFile = "tokenizer_config.json"
+	}
+	if d.SpecialTokensMapFile == "" {
+		d.SpecialTokensMapFile = "special_tokens_map.json"
+	}
+	if d.PreprocessorConfigFile == "" {
+		d.PreprocessorConfigFile = "preprocessor_config.json"
+	}
+	return d
+}
+
+// Private function to check that a descriptor has everything needed to run the model.
+func (d ModelDescriptor) validate() error {
+	if d.Model == "" {
+		return errors.New("model name is required")
+	}
+	if d.Dim <= 0 {
+		return fmt.Errorf("model %s: dimension must be positive, got %d", d.Model, d.Dim)
+	}
+	if d.Pooling != "" {
+		if err := validatePooling(d.Pooling); err != nil {
+			return fmt.Errorf("model %s: %w", d.Model, err)
+		}
+	}
+
+	for _, name := range d.InputNames {
+		switch name {
+		case inputIDsName, attentionMaskName, tokenTypeIDsName, pixelValuesName:
+		default:
+			return fmt.Errorf("model %s: unsupported input %q", d.Model, name)
+		}
+	}
+	// Image models take the pixel values only.
+	if slices.Contains(d.InputNames, pixelValuesName) && len(d.InputNames) > 1 {
+		return fmt.Errorf("model %s: the %q input of image models cannot be combined with other inputs, got %q", d.Model, pixelValuesName, d.InputNames)
+	}
+
+	if d.ArchiveSHA256 != "" {
+		if err := validateDigest(d.ArchiveSHA256); err != nil {
+			return fmt.Errorf("model %s: %w", d.Model, err)
+		}
+	}
+	for name, digest := range d.FileSHA256 {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("model %s: invalid file path %q", d.Model, name)
+		}
+		if err := validateDigest(digest); err != nil {
+			return fmt.Errorf("model %s: %s: %w", d.Model, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package fastembed_test
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestRegisterModel(t *testing.T) {
+	descriptor := fastembed.ModelDescriptor{
+		ModelInfo: fastembed.ModelInfo{
+			Model:   "test-register-model",
+			Dim:     384,
+			Pooling: fastembed.MeanPooling,
+		},
+		InputNames: []string{"input_ids", "attention_mask"},
+	}
+	if err := fastembed.RegisterModel(descriptor); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := fastembed.RegisterModel(descriptor); err == nil {
+		t.Error("Expected an error when registering a model twice")
+	}
+
+	found := false
+	for _, info := range fastembed.ListSupportedModels() {
+		if info.Model == descriptor.Model {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s to be listed", descriptor.Model)
+	}
+}
+
+func TestRegisterModelInvalid(t *testing.T) {
+	invalid := map[string]fastembed.ModelDescriptor{
+		"built-in name": {ModelInfo: fastembed.ModelInfo{Model: fastembed.BGESmallENV15, Dim: 384, Pooling: fastembed.CLSPooling}},
+		"no name":       {ModelInfo: fastembed.ModelInfo{Dim: 384, Pooling: fastembed.CLSPooling}},
+		"no dimension":  {ModelInfo: fastembed.ModelInfo{Model: "test-invalid", Pooling: fastembed.CLSPooling}},
+		"bad pooling":   {ModelInfo: fastembed.ModelInfo{Model: "test-invalid", Dim: 384, Pooling: "median"}},
+		"unknown input": {
+			ModelInfo:  fastembed.ModelInfo{Model: "test-invalid", Dim: 384, Pooling: fastembed.CLSPooling},
+			InputNames: []string{"input_ids", "pixel_mask"},
+		},
+		"image and text inputs": {
+			ModelInfo:  fastembed.ModelInfo{Model: "test-invalid", Dim: 384, Pooling: fastembed.CLSPooling},
+			InputNames: []string{"pixel_values", "input_ids"},
+		},
+		"bad archive digest": {
+			ModelInfo:     fastembed.ModelInfo{Model: "test-invalid", Dim: 384, Pooling: fastembed.CLSPooling},
+			ArchiveSHA256: "not-a-digest",
+		},
+		"bad file path": {
+			ModelInfo:  fastembed.ModelInfo{Model: "test-invalid", Dim: 384, Pooling: fastembed.CLSPooling},
+			FileSHA256: map[string]string{"../model.onnx": strings.Repeat("0", 64)},
+		},
+	}
+	for name, descriptor := range invalid {
+		if err := fastembed.RegisterModel(descriptor); err == nil {
+			t.Errorf("Expected an error for a descriptor with %s", name)
+		}
+	}
+}
+
+func TestNewFlagEmbeddingFromDir(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	// Load the files of the default model, downloaded above, as a custom model.
+	custom, err := fastembed.NewFlagEmbeddingFromDir(filepath.Join(fastembed.DefaultCacheDir(), string(fastembed.BGESmallENV15)), fastembed.ModelDescriptor{
+		ModelInfo: fastembed.ModelInfo{
+			Model:   "test-custom-bge-small",
+			Dim:     384,
+			Pooling: fastembed.CLSPooling,
+		},
+		QueryPrefix: "query: ",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer custom.Close()
+
+	expected, err := fe.QueryEmbed("hello world")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	result, err := custom.QueryEmbed("hello world")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, v := range expected {
+		if math.Abs(float64(result[i]-v)) > 1e-6 {
+			t.Errorf("Element %d mismatch: expected %.6f, got %.6f", i, v, result[i])
+		}
+	}
+}
+
+func TestTextModelPixelValues(t *testing.T) {
+	// The pixel values are fed by image models only, the text models are rejected before any file is read.
+	descriptor := fastembed.ModelDescriptor{
+		ModelInfo:  fastembed.ModelInfo{Model: "test-text-pixel-values", Dim: 384, Pooling: fastembed.CLSPooling},
+		InputNames: []string{"pixel_values"},
+	}
+	dir := t.TempDir()
+	if _, err := fastembed.NewFlagEmbeddingFromDir(dir, descriptor, nil); err == nil || !strings.Contains(err.Error(), "pixel_values") {
+		t.Errorf("Expected an error for a dense model, got %v", err)
+	}
+	sparseDescriptor := descriptor
+	sparseDescriptor.Pooling = fastembed.MaxPooling
+	if _, err := fastembed.NewSparseTextEmbeddingFromDir(dir, sparseDescriptor, nil); err == nil || !strings.Contains(err.Error(), "pixel_values") {
+		t.Errorf("Expected an error for a sparse model, got %v", err)
+	}
+	if _, err := fastembed.NewTextCrossEncoderFromDir(dir, descriptor, nil); err == nil || !strings.Contains(err.Error(), "pixel_values") {
+		t.Errorf("Expected an error for a cross-encoder model, got %v", err)
+	}
+	if _, err := fastembed.NewLateInteractionTextEmbeddingFromDir(dir, descriptor, nil); err == nil || !strings.Contains(err.Error(), "pixel_values") {
+		t.Errorf("Expected an error for a late-interaction model, got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package fastembed_test
+
+import (
+	"math"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestNormalize(t *testing.T) {
+	got := fastembed.Normalize([]float32{3, 4})
+	expected := []float32{0.6, 0.8}
+	for i, v := range expected {
+		if math.Abs(float64(got[i]-v)) > 1e-6 {
+			t.Errorf("Element %d mismatch: expected %.6f, got %.6f", i, v, got[i])
+		}
+	}
+}
+
+func TestNormalizeZeroVector(t *testing.T) {
+	for i, v := range fastembed.Normalize([]float32{0, 0, 0}) {
+		if v != 0 {
+			t.Errorf("Element %d: expected 0, got %.6f", i, v)
+		}
+	}
+}
+
+func TestNormalizeDisabled(t *testing.T) {
+	normalize := false
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{
+		Normalize: &normalize,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	raw, err := fe.QueryEmbed("hello world")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The normalized raw vector must match the default, normalized, output of the model.
+	normalized := fastembed.Normalize(raw)
+	if math.Abs(float64(normalized[0]-raw[0])) < 1e-6 {
+		t.Errorf("Expected an un-normalized vector, got %v", raw[:5])
+	}
+
+	fe2, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe2.Close()
+
+	expected, err := fe2.QueryEmbed("hello world")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for i, v := range expected {
+		if math.Abs(float64(normalized[i]-v)) > 1e-4 {
+			t.Errorf("Element %d mismatch: expected %.6f, got %.6f", i, v, normalized[i])
+		}
+	}
+}
+
+func TestEmbedWithOptionsNormalize(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	input := []string{"hello world"}
+	normalize := false
+	raw, err := fe.EmbedWithOptions(input, &fastembed.EmbedOptions{Normalize: &normalize})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected, err := fe.Embed(input, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// The call does not change the default of the model.
+	normalized := fastembed.Normalize(raw[0])
+	if math.Abs(float64(normalized[0]-raw[0][0])) < 1e-6 {
+		t.Errorf("Expected an un-normalized vector, got %v", raw[0][:5])
+	}
+	for i, v := range expected[0] {
+		if math.Abs(float64(normalized[i]-v)) > 1e-4 {
+			t.Errorf("Element %d mismatch: expected %.6f, got %.6f", i, v, normalized[i])
+		}
+	}
+}
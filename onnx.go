@@ -0,0 +1,328 @@
+package fastembed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/sugarme/tokenizer"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Struct holding what every model type needs to run an ONNX model:
+// the tokenizer, nil for image models, the onnxruntime session and the batch workers.
+type onnxModel struct {
+	tokenizer  *tokenizer.Tokenizer
+	descriptor ModelDescriptor
+	maxLength  int
+	modelPath  string
+	session    *ort.DynamicAdvancedSession
+	pool       *workerPool
+	output     ort.InputOutputInfo
+	closeMu    sync.Mutex
+	closed     bool
+}
+
+// Struct holding a tokenized batch, flattened as expected by onnxruntime.
+type encodedBatch struct {
+	encodings    []tokenizer.Encoding
+	inputIds     []int64
+	inputMask    []int64
+	inputTypeIds []int64
+	size         int
+	seqLen       int
+	metadata     []InputMetadata
+}
+
+// Private function to load the files of a text model, holding a reference on the onnxruntime environment on success.
+// reservedTokens is the number of tokens the model type adds to the inputs on top of the special tokens.
+func loadONNXModel(modelPath string, descriptor ModelDescriptor, options *InitOptions, spec graphSpec, reservedTokens int) (*onnxModel, error) {
+	if slices.Contains(descriptor.InputNames, pixelValuesName) {
+		return nil, fmt.Errorf("model %s: the %q input is only supported by image models, loaded with NewImageEmbedding", descriptor.Model, pixelValuesName)
+	}
+	tknzer, err := loadTokenizer(modelPath, descriptor, options.MaxLength, reservedTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := loadONNXSession(modelPath, descriptor, options, spec)
+	if err != nil {
+		return nil, err
+	}
+	m.tokenizer = tknzer
+	return m, nil
+}
+
+// Private function to load the ONNX file of a model, holding a reference on the onnxruntime environment on success.
+func loadONNXSession(modelPath string, descriptor ModelDescriptor, options *InitOptions, spec graphSpec) (*onnxModel, error) {
+	if err := acquireEnvironment(); err != nil {
+		return nil, err
+	}
+
+	m, err := newONNXModel(modelPath, descriptor, options, spec)
+	if err != nil {
+		releaseEnvironment()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Private function to load the model once the onnxruntime environment is acquired.
+func newONNXModel(modelPath string, descriptor ModelDescriptor, options *InitOptions, spec graphSpec) (*onnxModel, error) {
+	file := filepath.Join(modelPath, descriptor.ModelFile)
+	descriptor, output, err := inspectGraph(file, descriptor, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionConfig := options.sessionConfig()
+	sessionOptions, err := newSessionOptions(&sessionConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer sessionOptions.Destroy()
+
+	// The session is created once and reused for every batch.
+	// Loading and parsing the ONNX file is by far the most expensive part of an embedding call.
+	session, err := ort.NewDynamicAdvancedSession(file, descriptor.InputNames, []string{
+		descriptor.OutputName,
+	}, sessionOptions)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", descriptor.Model, err)
+	}
+
+	return &onnxModel{
+		descriptor: descriptor,
+		maxLength:  options.MaxLength,
+		modelPath:  modelPath,
+		session:    session,
+		output:     output,
+		pool:       newWorkerPool(options.MaxConcurrentBatches),
+	}, nil
+}
+
+// Private function to release the session and the reference on the onnxruntime environment.
+// Calling it more than once is a no-op.
+func (m *onnxModel) close() error {
+	m.closeMu.Lock()
+	defer m.closeMu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	// Running batches use the session, wait for them before destroying it.
+	m.pool.stop()
+	err := m.session.Destroy()
+	if envErr := releaseEnvironment(); err == nil {
+		err = envErr
+	}
+	return err
+}
+
+// Private function to look up the id of a token in the vocabulary.
+func (m *onnxModel) tokenID(token string) (int64, error) {
+	id, ok := m.tokenizer.TokenToId(token)
+	if !ok {
+		return 0, fmt.Errorf("model %s: token %q is not in the vocabulary", m.descriptor.Model, token)
+	}
+	return int64(id), nil
+}
+
+// Private function to tokenize a batch of input strings.
+func (m *onnxModel) encode(input []string) (*encodedBatch, error) {
+	encodings := make([]tokenizer.Encoding, len(input))
+	tokenCounts := make([]int, len(input))
+	for i, v := range input {
+		encoding, tokenCount, err := m.encodeSingle(v)
+		if err != nil {
+			return nil, err
+		}
+		encodings[i] = *encoding
+		tokenCounts[i] = tokenCount
+	}
+
+	padding := m.tokenizer.GetPadding()
+	if padding == nil {
+		return nil, errors.New("the tokenizer has no padding")
+	}
+	batch := newEncodedBatch(tokenizer.PadEncodings(encodings, *padding))
+	batch.metadata = newInputMetadata(encodings, tokenCounts)
+	return batch, nil
+}
+
+// Private function to tokenize a single input string with the special tokens, truncated to the maximum length of the tokenizer.
+// The truncation of the tokenizer is done here, as its LongestFirst strategy fails on a single sequence.
+// Returns the encoding, unpadded, and the number of tokens of the input before truncation.
+func (m *onnxModel) encodeSingle(input string) (*tokenizer.Encoding, int, error) {
+	encoding, err := m.tokenizer.EncodeSingleSequence(tokenizer.NewInputSequence(input), 0, tokenizer.Byte)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	postProcessor := m.tokenizer.GetPostProcessor()
+	addedTokens := 0
+	if postProcessor != nil {
+		addedTokens = postProcessor.AddedTokens(false)
+	}
+	tokenCount := encoding.Len() + addedTokens
+
+	if truncation := m.tokenizer.GetTruncation(); truncation != nil && tokenCount > truncation.MaxLength {
+		encoding, err = encoding.Truncate(truncation.MaxLength-addedTokens, 0)
+		if err != nil {
+			return nil, 0, fmt.Errorf("model %s: %w", m.descriptor.Model, err)
+		}
+		// The overflowing tokens are not embedded.
+		encoding.Overflowing = nil
+	}
+	return m.addSpecialTokens(encoding), tokenCount, nil
+}
+
+// Private function to add the special tokens of the model to a single encoding, which must fit in the maximum length.
+// Unlike Tokenizer.PostProcess, it neither truncates nor pads the encoding.
+func (m *onnxModel) addSpecialTokens(encoding *tokenizer.Encoding) *tokenizer.Encoding {
+	if postProcessor := m.tokenizer.GetPostProcessor(); postProcessor != nil {
+		return postProcessor.Process(encoding, nil, true)
+	}
+	return tokenizer.DefaultProcess(encoding, nil, true)
+}
+
+// Private function to tokenize a batch of pairs, made of the same first string and each of the second strings.
+// The token_type_ids tell the two strings of a pair apart.
+func (m *onnxModel) encodePairs(first string, seconds []string) (*encodedBatch, error) {
+	inputs := make([]tokenizer.EncodeInput, len(seconds))
+	for index, v := range seconds {
+		inputs[index] = tokenizer.NewDualEncodeInput(tokenizer.NewInputSequence(first), tokenizer.NewInputSequence(v))
+	}
+	return m.encodeInputs(inputs)
+}
+
+// Private function to tokenize a batch of single or pair inputs.
+func (m *onnxModel) encodeInputs(inputs []tokenizer.EncodeInput) (*encodedBatch, error) {
+	encodings, err := m.tokenizer.EncodeBatch(inputs, true)
+	if err != nil {
+		return nil, err
+	}
+	return newEncodedBatch(encodings), nil
+}
+
+// Private function to flatten encodings padded to the same length into a batch.
+func newEncodedBatch(encodings []tokenizer.Encoding) *encodedBatch {
+	inputIdsFlat, inputMaskFlat, inputTypeIdsFlat := make([]int64, 0), make([]int64, 0), make([]int64, 0)
+	for _, encoding := range encodings {
+		inputIds, inputMask, inputTypeIds := encodingToInt32(encoding.GetIds(), encoding.GetAttentionMask(), encoding.GetTypeIds())
+		inputIdsFlat = append(inputIdsFlat, inputIds...)
+		inputMaskFlat = append(inputMaskFlat, inputMask...)
+		inputTypeIdsFlat = append(inputTypeIdsFlat, inputTypeIds...)
+	}
+
+	return &encodedBatch{
+		encodings:    encodings,
+		inputIds:     inputIdsFlat,
+		inputMask:    inputMaskFlat,
+		inputTypeIds: inputTypeIdsFlat,
+		size:         len(encodings),
+		seqLen:       encodings[0].Len(),
+	}
+}
+
+// Private function to run the model on a tokenized batch.
+// Returns the flattened output, of the given shape.
+func (m *onnxModel) run(batch *encodedBatch, outputShape ort.Shape) ([]float32, error) {
+	inputShape := ort.NewShape(int64(batch.size), int64(batch.seqLen))
+
+	inputTensors := make([]ort.ArbitraryTensor, len(m.descriptor.InputNames))
+	for i, name := range m.descriptor.InputNames {
+		var data []int64
+		switch name {
+		case inputIDsName:
+			data = batch.inputIds
+		case attentionMaskName:
+			data = batch.inputMask
+		case tokenTypeIDsName:
+			data = batch.inputTypeIds
+		}
+
+		inputTensor, err := ort.NewTensor(inputShape, data)
+		if err != nil {
+			return nil, err
+		}
+		defer inputTensor.Destroy()
+		inputTensors[i] = inputTensor
+	}
+	return m.runTensors(inputTensors, outputShape)
+}
+
+// Private function to run the model on input tensors, in the order of the input names of the model.
+// Returns the flattened output, of the given shape.
+func (m *onnxModel) runTensors(inputTensors []ort.ArbitraryTensor, outputShape ort.Shape) ([]float32, error) {
+	outputTensor, err := ort.NewEmptyTensor[float32](outputShape)
+	if err != nil {
+		return nil, err
+	}
+	defer outputTensor.Destroy()
+
+	err = m.session.Run(inputTensors, []ort.ArbitraryTensor{outputTensor})
+	if err != nil {
+		return nil, err
+	}
+	return outputTensor.GetData(), nil
+}
+
+// Private function to tell whether the output of the model is pooled, of shape (batch size, dim).
+func (m *onnxModel) pooled() bool {
+	return len(m.output.Dimensions) == 2
+}
+
+// Private function to return the shape of a (batch size, sequence length, dim) output.
+func (b *encodedBatch) tokenShape(dim int) ort.Shape {
+	return ort.NewShape(int64(b.size), int64(b.seqLen), int64(dim))
+}
+
+// Private function to split n inputs into batches of batchSize and run them on the workers of the model.
+// runBatch is called with the bounds of each batch, for at most InitOptions.MaxConcurrentBatches batches at a time.
+// No new batches are started once the context is done or a batch failed,
+// and all the batches have returned by the time this function returns.
+func (m *onnxModel) runBatches(ctx context.Context, n, batchSize int, runBatch func(start, end int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errorCh := make(chan error, n+1)
+
+	for i := 0; i < n && ctx.Err() == nil; i += batchSize {
+		start := i
+		wg.Add(1)
+		err := m.pool.submit(ctx, func() {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				return
+			}
+			end := start + batchSize
+			if end > n {
+				end = n
+			}
+			if err := runBatch(start, end); err != nil {
+				errorCh <- err
+				cancel()
+			}
+		})
+		if err != nil {
+			wg.Done()
+			errorCh <- err
+			break
+		}
+	}
+	wg.Wait()
+	close(errorCh)
+
+	// We can aggregate the errors if we ever need to
+	if len(errorCh) > 0 {
+		return <-errorCh
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,212 @@
+package fastembed_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+// Writes the tokenizer.json file of a word-level tokenizer adding BERT special tokens, returning its path.
+func writeWordLevelTokenizer(t *testing.T, words []string) string {
+	t.Helper()
+	vocab := map[string]int{"[UNK]": 0, "[CLS]": 1, "[SEP]": 2}
+	for _, word := range words {
+		vocab[word] = len(vocab)
+	}
+	config := map[string]any{
+		"version":        "1.0",
+		"pre_tokenizer":  map[string]any{"type": "WhitespaceSplit"},
+		"post_processor": map[string]any{"type": "BertProcessing", "sep": []any{"[SEP]", 2}, "cls": []any{"[CLS]", 1}},
+		"model":          map[string]any{"type": "WordLevel", "vocab": vocab, "unk_token": "[UNK]"},
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncodeTruncatesLongInputs(t *testing.T) {
+	words := strings.Fields("one two three four five six")
+	fe, stop, err := fastembed.NewTokenizerOnlyEmbedding(writeWordLevelTokenizer(t, words), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// The long input keeps its special tokens, and the short one is padded to its length.
+	ids, _, err := fastembed.EncodeBatch(fe, []string{"one", "one two three four five six"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int64{{1, 3, 2, 0, 0}, {1, 3, 4, 5, 2}}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("Expected ids %v, got %v", expected, ids)
+	}
+}
+
+// The configuration files are written by many tokenizer versions, their unexpected values are errors rather than panics.
+func TestLoadTokenizerConfigs(t *testing.T) {
+	descriptor := fastembed.ModelDescriptor{ModelInfo: fastembed.ModelInfo{Model: "config-model", Dim: 2}}
+	testCases := []struct {
+		name                               string
+		config, tokenizerConfig, tokensMap string
+		expected                           int
+		err                                string
+	}{
+		{
+			name:            "token objects",
+			config:          `{"pad_token_id": 0}`,
+			tokenizerConfig: `{"model_max_length": 8, "pad_token": {"content": "[UNK]", "lstrip": false, "normalized": false}}`,
+			tokensMap:       `{"cls_token": "[CLS]", "sep_token": {"content": "[SEP]", "single_word": false}, "additional_special_tokens": ["[CLS]", {"content": "[SEP]"}]}`,
+			expected:        8,
+		},
+		{
+			name:            "no model_max_length nor pad_token_id",
+			config:          `{"pad_token_id": null}`,
+			tokenizerConfig: `{"pad_token": "[UNK]"}`,
+			tokensMap:       `{}`,
+			expected:        16,
+		},
+		{
+			name:            "model_max_length string",
+			config:          `{}`,
+			tokenizerConfig: `{"model_max_length": "8", "pad_token": "[UNK]"}`,
+			tokensMap:       `{}`,
+			err:             "model_max_length must be a number",
+		},
+		{
+			name:            "pad_token_id string",
+			config:          `{"pad_token_id": "0"}`,
+			tokenizerConfig: `{"pad_token": "[UNK]"}`,
+			tokensMap:       `{}`,
+			err:             "pad_token_id must be a number",
+		},
+		{
+			name:            "no pad_token",
+			config:          `{}`,
+			tokenizerConfig: `{}`,
+			tokensMap:       `{}`,
+			err:             "pad_token: missing token",
+		},
+		{
+			name:            "pad_token without content",
+			config:          `{}`,
+			tokenizerConfig: `{"pad_token": {"lstrip": false}}`,
+			tokensMap:       `{}`,
+			err:             "no content string",
+		},
+		{
+			name:            "special token number",
+			config:          `{}`,
+			tokenizerConfig: `{"pad_token": "[UNK]"}`,
+			tokensMap:       `{"cls_token": 1}`,
+			err:             "cls_token: a token must be a string or an object",
+		},
+		{
+			name:            "special token flag string",
+			config:          `{}`,
+			tokenizerConfig: `{"pad_token": "[UNK]"}`,
+			tokensMap:       `{"cls_token": {"content": "[CLS]", "lstrip": "no"}}`,
+			err:             "lstrip flag",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Dir(writeWordLevelTokenizer(t, []string{"one"}))
+			configs := map[string]string{
+				"config.json":             tc.config,
+				"tokenizer_config.json":   tc.tokenizerConfig,
+				"special_tokens_map.json": tc.tokensMap,
+			}
+			for name, content := range configs {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			length, err := fastembed.TruncationLength(dir, descriptor, 16, 0)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("Expected an error containing %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if length != tc.expected {
+				t.Errorf("Expected a length of %d, got %d", tc.expected, length)
+			}
+		})
+	}
+}
+
+func TestEmbedOverMaxLength(t *testing.T) {
+	long := strings.Repeat("a long input ", 20)
+	// Both inputs are truncated before they differ.
+	input := []string{long, long + "with a different ending"}
+
+	testCases := []struct {
+		name  string
+		embed func(fe *fastembed.FlagEmbedding) ([]([]float32), error)
+	}{
+		{
+			name: "Embed",
+			embed: func(fe *fastembed.FlagEmbedding) ([]([]float32), error) {
+				return fe.Embed(input, 1)
+			},
+		},
+		{
+			name: "MaxBatchTokens",
+			embed: func(fe *fastembed.FlagEmbedding) ([]([]float32), error) {
+				budgeted, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{MaxLength: 16, MaxBatchTokens: 64})
+				if err != nil {
+					return nil, err
+				}
+				defer budgeted.Close()
+				return budgeted.Embed(input, 2)
+			},
+		},
+		{
+			name: "EmbedLong",
+			embed: func(fe *fastembed.FlagEmbedding) ([]([]float32), error) {
+				results, err := fe.EmbedLong(input, &fastembed.LongEmbedOptions{ChunkLength: 8})
+				if err != nil {
+					return nil, err
+				}
+				// The chunks of both inputs start the same.
+				return [][]float32{results[0].Chunks[0].Embedding, results[1].Chunks[0].Embedding}, nil
+			},
+		},
+	}
+
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{MaxLength: 16})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			embeddings, err := tc.embed(fe)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(embeddings[0], embeddings[1]) {
+				t.Error("Expected the inputs to be truncated to the same embedding")
+			}
+		})
+	}
+}
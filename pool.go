@@ -0,0 +1,62 @@
+package fastembed
+
+import (
+	"context"
+	"sync"
+)
+
+// A fixed set of long-lived goroutines running the batches of a model.
+// Submitting blocks while every worker is busy, which bounds the number of batches,
+// and so the memory held by their tensors, regardless of the input size.
+type workerPool struct {
+	tasks chan func()
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// Private function to start a pool with the given number of workers.
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{
+		tasks: make(chan func()),
+		done:  make(chan struct{}),
+	}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+	for {
+		select {
+		case task := <-p.tasks:
+			task()
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Private function to hand a task to the next free worker.
+// Returns the context error if the context is done, or ErrModelClosed if the pool is stopped, before a worker is free.
+func (p *workerPool) submit(ctx context.Context, task func()) error {
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.done:
+		return ErrModelClosed
+	}
+}
+
+// Private function to stop the workers, waiting for the running tasks to complete.
+func (p *workerPool) stop() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	p.wg.Wait()
+}
@@ -0,0 +1,105 @@
+package fastembed
+
+import (
+	"fmt"
+	"math"
+)
+
+// Enum-type representing the strategies to pool the token embeddings into a single vector.
+type PoolingStrategy string
+
+const (
+	// The embedding of the first token, "[CLS]" for BERT models.
+	CLSPooling PoolingStrategy = "cls"
+	// The mean of the token embeddings, ignoring padding.
+	MeanPooling PoolingStrategy = "mean"
+	// The element-wise maximum of the token embeddings, ignoring padding.
+	MaxPooling PoolingStrategy = "max"
+	// The embedding of the last non-padding token.
+	LastTokenPooling PoolingStrategy = "last_token"
+	// The mean of the token embeddings weighted by their position, ignoring padding.
+	WeightedMeanPooling PoolingStrategy = "weighted_mean"
+)
+
+// Private function to check that a pooling strategy is known.
+func validatePooling(pooling PoolingStrategy) error {
+	switch pooling {
+	case CLSPooling, MeanPooling, MaxPooling, LastTokenPooling, WeightedMeanPooling:
+		return nil
+	default:
+		return fmt.Errorf("unknown pooling strategy %q", pooling)
+	}
+}
+
+// Private function to pool the token embeddings of a single input.
+// tokens holds seqLen token embeddings of size dim, mask holds the attention mask of the input.
+// The returned vector never shares memory with tokens.
+func pool(tokens []float32, mask []int64, dim int, pooling PoolingStrategy) []float32 {
+	switch pooling {
+	case MeanPooling:
+		return weightedMean(tokens, mask, dim, false)
+	case WeightedMeanPooling:
+		return weightedMean(tokens, mask, dim, true)
+	case MaxPooling:
+		return maxPool(tokens, mask, dim)
+	case LastTokenPooling:
+		last := 0
+		for i, m := range mask {
+			if m != 0 {
+				last = i
+			}
+		}
+		return append([]float32(nil), tokens[last*dim:(last+1)*dim]...)
+	default:
+		return append([]float32(nil), tokens[:dim]...)
+	}
+}
+
+// Private function to average the unmasked token embeddings.
+// With byPosition, the i-th token is weighted by i + 1 so that later tokens count more.
+func weightedMean(tokens []float32, mask []int64, dim int, byPosition bool) []float32 {
+	pooled := make([]float32, dim)
+	weightSum := float32(0.0)
+	for i, m := range mask {
+		if m == 0 {
+			continue
+		}
+		weight := float32(1.0)
+		if byPosition {
+			weight = float32(i + 1)
+		}
+		weightSum += weight
+		for j, val := range tokens[i*dim : (i+1)*dim] {
+			pooled[j] += val * weight
+		}
+	}
+
+	// Matches the clamp used by sentence-transformers to avoid dividing by zero.
+	weightSum = max(weightSum, 1e-9)
+	for j := range pooled {
+		pooled[j] /= weightSum
+	}
+	return pooled
+}
+
+// Private function to take the element-wise maximum of the unmasked token embeddings.
+func maxPool(tokens []float32, mask []int64, dim int) []float32 {
+	pooled := make([]float32, dim)
+	for j := range pooled {
+		pooled[j] = float32(math.Inf(-1))
+	}
+	found := false
+	for i, m := range mask {
+		if m == 0 {
+			continue
+		}
+		found = true
+		for j, val := range tokens[i*dim : (i+1)*dim] {
+			pooled[j] = max(pooled[j], val)
+		}
+	}
+	if !found {
+		return make([]float32, dim)
+	}
+	return pooled
+}
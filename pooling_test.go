@@ -0,0 +1,46 @@
+package fastembed_test
+
+import (
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestPooling(t *testing.T) {
+	// Three tokens of dimension 2, the last one being padding.
+	tokens := []float32{1, 4, 3, -2, 100, 100}
+	mask := []int64{1, 1, 0}
+
+	expected := map[fastembed.PoolingStrategy][]float32{
+		fastembed.CLSPooling:          {1, 4},
+		fastembed.MeanPooling:         {2, 1},
+		fastembed.MaxPooling:          {3, 4},
+		fastembed.LastTokenPooling:    {3, -2},
+		fastembed.WeightedMeanPooling: {7.0 / 3, 0},
+	}
+
+	for pooling, want := range expected {
+		got := fastembed.Pool(tokens, mask, 2, pooling)
+		if len(got) != len(want) {
+			t.Fatalf("Expected length %d for %s, got %d", len(want), pooling, len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Element %d mismatch for %s: expected %.6f, got %.6f", i, pooling, want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestPoolingFullyMasked(t *testing.T) {
+	tokens := []float32{1, 2, 3, 4}
+	mask := []int64{0, 0}
+
+	for _, pooling := range []fastembed.PoolingStrategy{fastembed.MeanPooling, fastembed.MaxPooling, fastembed.WeightedMeanPooling} {
+		for i, v := range fastembed.Pool(tokens, mask, 2, pooling) {
+			if v != 0 {
+				t.Errorf("Element %d for %s: expected 0, got %.6f", i, pooling, v)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package fastembed
+
+import (
+	"sync"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+// The phases of the retrieval of a model reported to a ProgressFunc.
+type ProgressPhase string
+
+const (
+	// The download of a file, the archive of the model for GCSSource.
+	PhaseDownload ProgressPhase = "download"
+	// The extraction of the downloaded archive.
+	PhaseExtract ProgressPhase = "extract"
+	// The verification of the files of the model against the digests of its descriptor.
+	PhaseVerify ProgressPhase = "verify"
+)
+
+// Struct describing the progress of a phase of the retrieval of a model
+// Model: The model being retrieved
+// Phase: The phase in progress
+// File: The file being downloaded or verified, the name of the model for its archive and for the whole model
+// Done: The number of bytes processed so far
+// Total: The total number of bytes, -1 if unknown until the phase completes
+type Progress struct {
+	Model EmbeddingModel
+	Phase ProgressPhase
+	File  string
+	Done  int64
+	Total int64
+}
+
+// Function receiving the progress of the retrieval of the models, set with InitOptions.ProgressFunc
+// It is called when a phase starts, as the bytes are processed, and once with Done equal to Total when the phase completes.
+// A retried download starts again from the bytes already downloaded, so Done can go back.
+// NOTE:
+// It is called from the goroutine initializing the model,
+// so it must be safe for concurrent use when several models are initialized at the same time.
+type ProgressFunc func(Progress)
+
+// Key of the progress bars drawn by ProgressBar.
+type progressKey struct {
+	model EmbeddingModel
+	phase ProgressPhase
+	file  string
+}
+
+// Function to return a ProgressFunc drawing progress bars to the standard error.
+// It is the default when InitOptions.ShowDownloadProgress is true.
+func ProgressBar() ProgressFunc {
+	var mu sync.Mutex
+	bars := make(map[progressKey]*progressbar.ProgressBar)
+
+	return func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		key := progressKey{model: p.Model, phase: p.Phase, file: p.File}
+		completed := p.Done == p.Total
+		bar, ok := bars[key]
+		if !ok || (!completed && bar.GetMax64() != p.Total) {
+			bar = progressbar.DefaultBytes(p.Total, progressDescription(p))
+			bars[key] = bar
+		}
+		if completed {
+			// The total of a download of unknown size is only known once completed.
+			if bar.GetMax64() != p.Total {
+				bar.ChangeMax64(p.Total)
+			}
+			delete(bars, key)
+		}
+		_ = bar.Set64(p.Done)
+	}
+}
+
+// Private function to describe a progress bar.
+func progressDescription(p Progress) string {
+	switch p.Phase {
+	case PhaseDownload:
+		return "Downloading " + p.File
+	case PhaseExtract:
+		return "Extracting " + p.File
+	case PhaseVerify:
+		return "Verifying " + p.File
+	}
+	return p.File
+}
+
+// Private function to report progress, if there is a ProgressFunc.
+func (f ProgressFunc) report(p Progress) {
+	if f != nil {
+		f(p)
+	}
+}
+
+// Writer reporting the bytes written through it as the progress of a phase.
+type progressWriter struct {
+	progress Progress
+	report   ProgressFunc
+}
+
+func (w *progressWriter) Write(data []byte) (int, error) {
+	w.progress.Done += int64(len(data))
+	// The completion is reported by complete, once.
+	if w.progress.Done != w.progress.Total {
+		w.report.report(w.progress)
+	}
+	return len(data), nil
+}
+
+// Private function to report the start of the phase, or of a resumed download.
+func (w *progressWriter) start() {
+	w.report.report(w.progress)
+}
+
+// Private function to report the completion of the phase, with the bytes written as total.
+func (w *progressWriter) complete() {
+	w.progress.Total = w.progress.Done
+	w.report.report(w.progress)
+}
@@ -0,0 +1,64 @@
+package fastembed_test
+
+import (
+	"sync"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestDownloadProgress(t *testing.T) {
+	archive, content := buildModelArchive(t, "test-model")
+	// The first connection is dropped, so the download is resumed.
+	server := newFlakyServer(t, archive, 1, true)
+
+	descriptor := testModelDescriptor
+	descriptor.URL = server.URL + "/test-model.tar.gz"
+	descriptor.ArchiveSHA256 = sha256Hex(archive)
+	descriptor.FileSHA256 = map[string]string{"model_optimized.onnx": sha256Hex(content)}
+
+	var mu sync.Mutex
+	var events []fastembed.Progress
+	progress := func(p fastembed.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, p)
+	}
+	if _, err := fastembed.DownloadModel(descriptor, t.TempDir(), server.Client(), 1, progress); err != nil {
+		t.Fatal(err)
+	}
+
+	totals := map[fastembed.ProgressPhase]int64{
+		fastembed.PhaseDownload: int64(len(archive)),
+		fastembed.PhaseExtract:  int64(len(archive)),
+		fastembed.PhaseVerify:   int64(len(content)),
+	}
+	phases := []fastembed.ProgressPhase{fastembed.PhaseDownload, fastembed.PhaseExtract, fastembed.PhaseVerify}
+	phase := 0
+	for i, event := range events {
+		if event.Model != "test-model" || event.File != "test-model" {
+			t.Fatalf("Unexpected model or file in event %d: %+v", i, event)
+		}
+		if event.Phase != phases[phase] {
+			phase++
+			if phase == len(phases) || event.Phase != phases[phase] {
+				t.Fatalf("Unexpected phase in event %d: %+v", i, event)
+			}
+			if previous := events[i-1]; previous.Done != previous.Total {
+				t.Errorf("Expected the %s phase to complete, got %+v", previous.Phase, previous)
+			}
+		}
+		if event.Total != totals[event.Phase] || event.Done > event.Total {
+			t.Errorf("Unexpected progress in event %d: %+v", i, event)
+		}
+		if event.Done == event.Total && i+1 < len(events) && events[i+1].Phase == event.Phase {
+			t.Errorf("Expected the completion of the %s phase to be reported once", event.Phase)
+		}
+	}
+	if phase != len(phases)-1 {
+		t.Fatalf("Expected every phase to be reported, got %d phases", phase+1)
+	}
+	if last := events[len(events)-1]; last.Done != last.Total {
+		t.Errorf("Expected the verification to complete, got %+v", last)
+	}
+}
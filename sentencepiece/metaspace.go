@@ -0,0 +1,117 @@
+package sentencepiece
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/normalizer"
+)
+
+// Enum-type representing when Metaspace adds a replacement character in front of the text.
+type PrependScheme string
+
+const (
+	// In front of every split of the text, such as the parts between special tokens.
+	PrependAlways PrependScheme = "always"
+	// In front of the first split of the text only.
+	PrependFirst PrependScheme = "first"
+	// Never.
+	PrependNever PrependScheme = "never"
+)
+
+// Pre-tokenizer of the SentencePiece models, replacing the spaces with a meta character, "▁" usually,
+// and splitting the text in front of the meta characters. It also decodes the tokens back into text.
+// Replacement: The character replacing the spaces
+// PrependScheme: When to add a replacement character in front of the text, so the first word looks like the others
+// Split: Whether to split the text in front of the replacement characters
+type Metaspace struct {
+	Replacement   string
+	PrependScheme PrependScheme
+	Split         bool
+}
+
+// Function to create a Metaspace pre-tokenizer.
+func NewMetaspace(replacement string, prependScheme PrependScheme, split bool) (*Metaspace, error) {
+	switch prependScheme {
+	case PrependAlways, PrependFirst, PrependNever:
+	default:
+		return nil, fmt.Errorf("metaspace: unknown prepend scheme %q", prependScheme)
+	}
+	if replacement == "" {
+		return nil, fmt.Errorf("metaspace: a replacement character is required")
+	}
+	return &Metaspace{Replacement: replacement, PrependScheme: prependScheme, Split: split}, nil
+}
+
+// Function to replace the spaces and split the text.
+func (m *Metaspace) PreTokenize(pretokenized *tokenizer.PreTokenizedString) (*tokenizer.PreTokenizedString, error) {
+	return pretokenized.Split(func(_ int, n *normalizer.NormalizedString) []tokenizer.SplitIdx {
+		n = m.replaceSpaces(n)
+		if !m.Split {
+			return []tokenizer.SplitIdx{{Normalized: n}}
+		}
+
+		text := n.GetNormalized()
+		if text == "" {
+			return nil
+		}
+		// Every split but the first starts with a replacement character.
+		var splits []tokenizer.SplitIdx
+		for start := 0; ; {
+			end := len(text)
+			next := strings.Index(text[start+1:], m.Replacement)
+			if next >= 0 {
+				end = start + 1 + next
+			}
+			splits = append(splits, tokenizer.SplitIdx{Normalized: n.Slice(normalizer.NewRange(start, end, normalizer.NormalizedTarget))})
+			if next < 0 {
+				break
+			}
+			start = end
+		}
+		return splits
+	}), nil
+}
+
+// Private function to replace the spaces, and add the replacement character in front of the text according to the prepend scheme.
+func (m *Metaspace) replaceSpaces(n *normalizer.NormalizedString) *normalizer.NormalizedString {
+	text := n.GetNormalized()
+	var edits []edit
+	prepend := !strings.HasPrefix(text, " ") && !strings.HasPrefix(text, m.Replacement)
+	switch m.PrependScheme {
+	case PrependFirst:
+		prepend = prepend && n.OffsetsOriginal()[0] == 0
+	case PrependNever:
+		prepend = false
+	}
+	if prepend {
+		edits = append(edits, edit{content: m.Replacement})
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ' ' {
+			edits = append(edits, edit{start: i, end: i + 1, content: m.Replacement})
+		}
+	}
+	return rewrite(n, edits)
+}
+
+// Function to decode the tokens, turning the replacement characters back into spaces.
+// The replacement characters of the first token were prepended, and are removed, unless the prepend scheme is never.
+func (m *Metaspace) DecodeChain(tokens []string) []string {
+	decoded := make([]string, len(tokens))
+	for i, token := range tokens {
+		space := " "
+		if i == 0 && m.PrependScheme != PrependNever {
+			space = ""
+		}
+		decoded[i] = strings.ReplaceAll(token, m.Replacement, space)
+	}
+	return decoded
+}
+
+// Function to decode the tokens into a string.
+func (m *Metaspace) Decode(tokens []string) string {
+	return strings.Join(m.DecodeChain(tokens), "")
+}
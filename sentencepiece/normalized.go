@@ -0,0 +1,111 @@
+package sentencepiece
+
+import (
+	"regexp"
+
+	"github.com/sugarme/tokenizer/normalizer"
+)
+
+// Struct describing the replacement of a byte range of a normalized string.
+// An empty range inserts the content.
+type edit struct {
+	start   int
+	end     int
+	content string
+}
+
+// Normalizer replacing the matches of a pattern, such as the runs of spaces, keeping the alignments.
+type replace struct {
+	pattern *regexp.Regexp
+	content string
+}
+
+// Function to replace the matches of the pattern with the content.
+func (r *replace) Normalize(n *normalizer.NormalizedString) (*normalizer.NormalizedString, error) {
+	var edits []edit
+	for _, match := range r.pattern.FindAllStringIndex(n.GetNormalized(), -1) {
+		edits = append(edits, edit{start: match[0], end: match[1], content: r.content})
+	}
+	return rewrite(n, edits), nil
+}
+
+// Private function to apply edits, sorted and not overlapping, to a normalized string.
+// The alignments are computed directly: the bytes of a replacement are aligned with the whole replaced range,
+// and inserted bytes with the character following them, or the last character at the end of the string.
+func rewrite(n *normalizer.NormalizedString, edits []edit) *normalizer.NormalizedString {
+	if len(edits) == 0 {
+		return n
+	}
+	normalized := n.GetNormalized()
+	alignments := n.Alignments()
+	original := n.GetOriginal()
+
+	var builder []byte
+	newAlignments := make([][]int, 0, len(normalized))
+	keep := func(start, end int) {
+		builder = append(builder, normalized[start:end]...)
+		for _, alignment := range alignments[start:end] {
+			newAlignments = append(newAlignments, []int{alignment[0], alignment[1]})
+		}
+	}
+
+	position := 0
+	for _, e := range edits {
+		keep(position, e.start)
+		span := alignedSpan(alignments, e.start, e.end)
+		builder = append(builder, e.content...)
+		for i := 0; i < len(e.content); i++ {
+			newAlignments = append(newAlignments, []int{span[0], span[1]})
+		}
+		position = e.end
+	}
+	keep(position, len(normalized))
+
+	return normalizer.NewNormalizedString(original, string(builder), newAlignments, originalAlignments(len(original), newAlignments), n.Shift())
+}
+
+// Private function to return the original range of a normalized range.
+func alignedSpan(alignments [][]int, start, end int) []int {
+	if len(alignments) == 0 {
+		return []int{0, 0}
+	}
+	if start == end {
+		// An insertion is aligned with the next character, or the last one.
+		if start == len(alignments) {
+			start--
+		}
+		return alignments[start]
+	}
+
+	span := []int{alignments[start][0], alignments[start][1]}
+	for _, alignment := range alignments[start+1 : end] {
+		span[0] = min(span[0], alignment[0])
+		span[1] = max(span[1], alignment[1])
+	}
+	return span
+}
+
+// Private function to compute the normalized range of each byte of the original string, from the alignments.
+// A removed byte gets an empty range, where it was removed.
+func originalAlignments(originalLength int, alignments [][]int) [][]int {
+	result := make([][]int, originalLength)
+	for i, alignment := range alignments {
+		for j := alignment[0]; j < alignment[1] && j < originalLength; j++ {
+			if result[j] == nil {
+				result[j] = []int{i, i + 1}
+			} else {
+				result[j][1] = i + 1
+			}
+		}
+	}
+
+	position := 0
+	for j, alignment := range result {
+		if alignment == nil {
+			result[j] = []int{position, position}
+		} else {
+			position = alignment[1]
+		}
+	}
+	return result
+}
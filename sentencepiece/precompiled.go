@@ -0,0 +1,124 @@
+package sentencepiece
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/rivo/uniseg"
+	"github.com/sugarme/tokenizer/normalizer"
+)
+
+// Normalizer of the SentencePiece models, rewriting the text with the rules of their precompiled_charsmap
+// The charsmap is a double array trie matching characters, followed by the "\x00" terminated replacements the trie points to.
+// NOTE:
+// The text is rewritten grapheme by grapheme, and character by character for the graphemes without a rule,
+// exactly as the Hugging Face tokenizers do, so the models see the text they were trained on.
+type Precompiled struct {
+	trie         []uint32
+	replacements string
+}
+
+// Function to create the normalizer of a precompiled_charsmap, once decoded from base64.
+func NewPrecompiled(charsmap []byte) (*Precompiled, error) {
+	if len(charsmap) < 4 {
+		return nil, errors.New("precompiled charsmap: too short")
+	}
+	trieSize := binary.LittleEndian.Uint32(charsmap)
+	if trieSize%4 != 0 || uint64(trieSize) > uint64(len(charsmap)-4) {
+		return nil, errors.New("precompiled charsmap: invalid trie size")
+	}
+
+	trie := make([]uint32, trieSize/4)
+	for i := range trie {
+		trie[i] = binary.LittleEndian.Uint32(charsmap[4+4*i:])
+	}
+	return &Precompiled{trie: trie, replacements: string(charsmap[4+trieSize:])}, nil
+}
+
+// Function to normalize a string, keeping the alignments with the original string.
+func (p *Precompiled) Normalize(n *normalizer.NormalizedString) (*normalizer.NormalizedString, error) {
+	text := n.GetNormalized()
+	var edits []edit
+
+	graphemes := uniseg.NewGraphemes(text)
+	for graphemes.Next() {
+		start, end := graphemes.Positions()
+		grapheme := text[start:end]
+		// Only the short graphemes are looked up as a whole.
+		if len(grapheme) < 6 {
+			if replacement, ok := p.transform(grapheme); ok {
+				edits = append(edits, edit{start: start, end: end, content: replacement})
+				continue
+			}
+		}
+		for offset, char := range grapheme {
+			charEnd := offset + len(string(char))
+			if replacement, ok := p.transform(grapheme[offset:charEnd]); ok {
+				edits = append(edits, edit{start: start + offset, end: start + charEnd, content: replacement})
+			}
+		}
+	}
+	return rewrite(n, edits), nil
+}
+
+// Private function to return the replacement of the shortest prefix of a chunk that the trie matches, if any.
+func (p *Precompiled) transform(chunk string) (string, bool) {
+	index, ok := p.shortestPrefixValue(chunk)
+	if !ok || index >= len(p.replacements) {
+		return "", false
+	}
+	replacement := p.replacements[index:]
+	if end := strings.IndexByte(replacement, 0); end >= 0 {
+		replacement = replacement[:end]
+	}
+	return replacement, true
+}
+
+// Private function to search the double array trie for the first prefix of the key it holds,
+// returning the value of the prefix.
+func (p *Precompiled) shortestPrefixValue(key string) (int, bool) {
+	if len(p.trie) == 0 {
+		return 0, false
+	}
+	position := unitOffset(p.trie[0])
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == 0 {
+			break
+		}
+		position ^= uint32(c)
+		if int(position) >= len(p.trie) {
+			return 0, false
+		}
+		unit := p.trie[position]
+		if unitLabel(unit) != uint32(c) {
+			return 0, false
+		}
+		position ^= unitOffset(unit)
+		if unitHasLeaf(unit) {
+			if int(position) >= len(p.trie) {
+				return 0, false
+			}
+			return int(unitValue(p.trie[position])), true
+		}
+	}
+	return 0, false
+}
+
+// Private functions decoding the units of the double array trie, as in the Darts library used by SentencePiece.
+func unitHasLeaf(unit uint32) bool {
+	return (unit>>8)&1 == 1
+}
+
+func unitValue(unit uint32) uint32 {
+	return unit & (1<<31 - 1)
+}
+
+func unitLabel(unit uint32) uint32 {
+	return unit & (1<<31 | 0xFF)
+}
+
+func unitOffset(unit uint32) uint32 {
+	return (unit >> 10) << ((unit & (1 << 9)) >> 6)
+}
@@ -0,0 +1,246 @@
+// Package sentencepiece loads the tokenizers of the SentencePiece models, such as XLM-RoBERTa and multilingual-e5,
+// from their Hugging Face tokenizer.json file.
+// It implements the Unigram model, the Precompiled normalizer and the Metaspace pre-tokenizer
+// for the github.com/sugarme/tokenizer package, which does not support them.
+package sentencepiece
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/sugarme/tokenizer"
+	"github.com/sugarme/tokenizer/decoder"
+	"github.com/sugarme/tokenizer/normalizer"
+	"github.com/sugarme/tokenizer/pretokenizer"
+	"github.com/sugarme/tokenizer/pretrained"
+)
+
+// The tokenizer.json file, the model being decoded once its type is known.
+type tokenizerConfig struct {
+	tokenizer.Config
+	Model json.RawMessage `json:"model"`
+}
+
+// The "model" of the tokenizer.json files of the Unigram models.
+type unigramConfig struct {
+	Type         string  `json:"type"`
+	UnknownID    *int    `json:"unk_id"`
+	Vocab        []Piece `json:"vocab"`
+	ByteFallback bool    `json:"byte_fallback"`
+}
+
+// Function to load a tokenizer from a tokenizer.json file, as pretrained.FromFile does
+// The Unigram models, the Precompiled and Replace normalizers and the Metaspace pre-tokenizers and decoders
+// are created by this package, the rest of the tokenizer by the pretrained package.
+// It loads the tokenizers of the other models too, so any tokenizer.json file can be loaded with it.
+func FromFile(path string) (*tokenizer.Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config tokenizerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	model, err := createModel(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating model failed: %w", err)
+	}
+	tk := tokenizer.NewTokenizer(model)
+
+	n, err := createNormalizer(config.Normalizer)
+	if err != nil {
+		return nil, fmt.Errorf("creating normalizer failed: %w", err)
+	}
+	tk.WithNormalizer(n)
+
+	preTokenizer, err := createPreTokenizer(config.PreTokenizer)
+	if err != nil {
+		return nil, fmt.Errorf("creating pre-tokenizer failed: %w", err)
+	}
+	tk.WithPreTokenizer(preTokenizer)
+
+	postProcessor, err := pretrained.CreatePostProcessor(config.PostProcessor)
+	if err != nil {
+		return nil, fmt.Errorf("creating post-processor failed: %w", err)
+	}
+	tk.WithPostProcessor(postProcessor)
+
+	d, err := createDecoder(config.Decoder)
+	if err != nil {
+		return nil, fmt.Errorf("creating decoder failed: %w", err)
+	}
+	tk.WithDecoder(d)
+
+	specialTokens, addedTokens := pretrained.CreateAddedTokens(config.AddedTokens)
+	if len(specialTokens) > 0 {
+		tk.AddSpecialTokens(specialTokens)
+	}
+	if len(addedTokens) > 0 {
+		tk.AddTokens(addedTokens)
+	}
+
+	truncation, err := pretrained.CreateTruncationParams(config.Truncation)
+	if err != nil {
+		return nil, fmt.Errorf("creating truncation failed: %w", err)
+	}
+	tk.WithTruncation(truncation)
+
+	padding, err := pretrained.CreatePaddingParams(config.Padding)
+	if err != nil {
+		return nil, fmt.Errorf("creating padding failed: %w", err)
+	}
+	tk.WithPadding(padding)
+
+	return tk, nil
+}
+
+// Private function to create the model of a tokenizer.json file.
+func createModel(config tokenizerConfig) (tokenizer.Model, error) {
+	var unigram unigramConfig
+	if err := json.Unmarshal(config.Model, &unigram); err != nil || unigram.Type != "Unigram" {
+		// Not a Unigram model, or an invalid one that the pretrained package reports.
+		var model map[string]interface{}
+		if err := json.Unmarshal(config.Model, &model); err != nil {
+			return nil, err
+		}
+		config.Config.Model = model
+		return pretrained.CreateModel(&config.Config)
+	}
+
+	unknownID := -1
+	if unigram.UnknownID != nil {
+		unknownID = *unigram.UnknownID
+	}
+	return NewUnigram(unigram.Vocab, unknownID, unigram.ByteFallback)
+}
+
+// Private function to create a normalizer of a tokenizer.json file.
+func createNormalizer(config map[string]interface{}) (normalizer.Normalizer, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	switch config["type"] {
+	case "Precompiled":
+		encoded, _ := config["precompiled_charsmap"].(string)
+		charsmap, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("precompiled charsmap: %w", err)
+		}
+		return NewPrecompiled(charsmap)
+	case "Replace":
+		return createReplace(config)
+	case "Sequence":
+		configs, _ := config["normalizers"].([]interface{})
+		normalizers := make([]normalizer.Normalizer, 0, len(configs))
+		for _, c := range configs {
+			params, ok := c.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid normalizer %v", c)
+			}
+			n, err := createNormalizer(params)
+			if err != nil {
+				return nil, err
+			}
+			normalizers = append(normalizers, n)
+		}
+		return normalizer.NewSequence(normalizers), nil
+	}
+	return pretrained.CreateNormalizer(config)
+}
+
+// Private function to create a pre-tokenizer of a tokenizer.json file.
+func createPreTokenizer(config map[string]interface{}) (tokenizer.PreTokenizer, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	switch config["type"] {
+	case "Metaspace":
+		return createMetaspace(config)
+	case "Sequence":
+		configs, _ := config["pretokenizers"].([]interface{})
+		preTokenizers := make([]tokenizer.PreTokenizer, 0, len(configs))
+		for _, c := range configs {
+			params, ok := c.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid pre-tokenizer %v", c)
+			}
+			preTokenizer, err := createPreTokenizer(params)
+			if err != nil {
+				return nil, err
+			}
+			preTokenizers = append(preTokenizers, preTokenizer)
+		}
+		return pretokenizer.NewSequence(preTokenizers), nil
+	}
+	return pretrained.CreatePreTokenizer(config)
+}
+
+// Private function to create a decoder of a tokenizer.json file.
+func createDecoder(config map[string]interface{}) (tokenizer.Decoder, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	switch config["type"] {
+	case "Metaspace":
+		return createMetaspace(config)
+	case "Sequence":
+		configs, _ := config["decoders"].([]interface{})
+		decoders := make([]tokenizer.Decoder, 0, len(configs))
+		for _, c := range configs {
+			params, ok := c.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("invalid decoder %v", c)
+			}
+			d, err := createDecoder(params)
+			if err != nil {
+				return nil, err
+			}
+			decoders = append(decoders, d)
+		}
+		return decoder.NewSequence(decoders), nil
+	}
+	return pretrained.CreateDecoder(config)
+}
+
+// Private function to create a Metaspace pre-tokenizer or decoder
+// The prepend scheme of the older files is given by "add_prefix_space", and the split defaults to true.
+func createMetaspace(config map[string]interface{}) (*Metaspace, error) {
+	replacement, _ := config["replacement"].(string)
+	prependScheme := PrependAlways
+	if scheme, ok := config["prepend_scheme"].(string); ok {
+		prependScheme = PrependScheme(scheme)
+	} else if addPrefixSpace, ok := config["add_prefix_space"].(bool); ok && !addPrefixSpace {
+		prependScheme = PrependNever
+	}
+	split := true
+	if value, ok := config["split"].(bool); ok {
+		split = value
+	}
+	return NewMetaspace(replacement, prependScheme, split)
+}
+
+// Private function to create a Replace normalizer, replacing a string or the matches of a regular expression.
+func createReplace(config map[string]interface{}) (normalizer.Normalizer, error) {
+	pattern, _ := config["pattern"].(map[string]interface{})
+	content, _ := config["content"].(string)
+	if value, ok := pattern["String"].(string); ok {
+		return &replace{pattern: regexp.MustCompile(regexp.QuoteMeta(value)), content: content}, nil
+	}
+	if value, ok := pattern["Regex"].(string); ok {
+		expression, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Replace pattern: %w", err)
+		}
+		return &replace{pattern: expression, content: content}, nil
+	}
+	return nil, fmt.Errorf("invalid Replace pattern %v", config["pattern"])
+}
@@ -0,0 +1,158 @@
+package sentencepiece_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/anush008/fastembed-go/sentencepiece"
+	"github.com/sugarme/tokenizer/normalizer"
+	"github.com/sugarme/tokenizer/spm"
+)
+
+// Writes the tokenizer.json file of a small XLM-RoBERTa like tokenizer, returning its path.
+// The normalizer uses the nmt_nfkc charsmap of the XLM-RoBERTa models.
+func writeTokenizerFile(t *testing.T) string {
+	t.Helper()
+	metaspace := map[string]any{"type": "Metaspace", "replacement": "▁", "add_prefix_space": true, "prepend_scheme": "always"}
+	config := map[string]any{
+		"version": "1.0",
+		"added_tokens": []any{
+			map[string]any{"id": 0, "content": "<s>", "special": true},
+			map[string]any{"id": 1, "content": "<pad>", "special": true},
+			map[string]any{"id": 2, "content": "</s>", "special": true},
+			map[string]any{"id": 3, "content": "<unk>", "special": true},
+		},
+		"normalizer": map[string]any{
+			"type": "Sequence",
+			"normalizers": []any{
+				map[string]any{"type": "Precompiled", "precompiled_charsmap": base64.StdEncoding.EncodeToString(spm.NmtNfkc())},
+				map[string]any{"type": "Replace", "pattern": map[string]any{"Regex": " {2,}"}, "content": " "},
+			},
+		},
+		"pre_tokenizer": metaspace,
+		"post_processor": map[string]any{
+			"type": "TemplateProcessing",
+			"single": []any{
+				map[string]any{"SpecialToken": map[string]any{"id": "<s>", "type_id": 0}},
+				map[string]any{"Sequence": map[string]any{"id": "A", "type_id": 0}},
+				map[string]any{"SpecialToken": map[string]any{"id": "</s>", "type_id": 0}},
+			},
+			"pair": []any{
+				map[string]any{"SpecialToken": map[string]any{"id": "<s>", "type_id": 0}},
+				map[string]any{"Sequence": map[string]any{"id": "A", "type_id": 0}},
+				map[string]any{"SpecialToken": map[string]any{"id": "</s>", "type_id": 0}},
+				map[string]any{"SpecialToken": map[string]any{"id": "</s>", "type_id": 0}},
+				map[string]any{"Sequence": map[string]any{"id": "B", "type_id": 0}},
+				map[string]any{"SpecialToken": map[string]any{"id": "</s>", "type_id": 0}},
+			},
+			"special_tokens": map[string]any{
+				"<s>":  map[string]any{"id": "<s>", "ids": []int{0}, "tokens": []string{"<s>"}},
+				"</s>": map[string]any{"id": "</s>", "ids": []int{2}, "tokens": []string{"</s>"}},
+			},
+		},
+		"decoder": metaspace,
+		"model": map[string]any{
+			"type":   "Unigram",
+			"unk_id": 3,
+			"vocab": []any{
+				[]any{"<s>", 0.0}, []any{"<pad>", 0.0}, []any{"</s>", 0.0}, []any{"<unk>", 0.0},
+				[]any{"▁", -2.0}, []any{"▁Hello", -5.0}, []any{"▁hell", -6.0}, []any{"o", -3.0},
+				[]any{"▁world", -5.0}, []any{"▁wor", -7.0}, []any{"ld", -4.0}, []any{"!", -3.0},
+				[]any{"fi", -4.0}, []any{"1", -3.0},
+			},
+		},
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFromFile(t *testing.T) {
+	tk, err := sentencepiece.FromFile(writeTokenizerFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Full-width characters are normalized, and the runs of spaces collapsed.
+	input := "Hello  ｗｏｒｌｄ! 零"
+	encoding, err := tk.EncodeSingle(input, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedTokens := []string{"<s>", "▁Hello", "▁world", "!", "▁", "零", "</s>"}
+	if tokens := encoding.GetTokens(); !reflect.DeepEqual(tokens, expectedTokens) {
+		t.Errorf("Expected tokens %q, got %q", expectedTokens, tokens)
+	}
+	expectedIDs := []int{0, 5, 8, 11, 4, 3, 2}
+	if ids := encoding.GetIds(); !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("Expected ids %v, got %v", expectedIDs, ids)
+	}
+	for _, typeID := range encoding.GetTypeIds() {
+		if typeID != 0 {
+			t.Errorf("Expected type ids of 0, got %v", encoding.GetTypeIds())
+			break
+		}
+	}
+
+	// The offsets point to the original text, the replaced spaces being part of the next word.
+	expectedOffsets := [][]int{{0, 0}, {0, 5}, {5, 22}, {22, 23}, {23, 24}, {24, 27}, {0, 0}}
+	if offsets := encoding.GetOffsets(); !reflect.DeepEqual(offsets, expectedOffsets) {
+		t.Errorf("Expected offsets %v, got %v", expectedOffsets, offsets)
+	}
+
+	if decoded := tk.Decode(encoding.GetIds()[:4], true); decoded != "Hello world!" {
+		t.Errorf("Expected %q to be decoded, got %q", "Hello world!", decoded)
+	}
+}
+
+func TestPrecompiled(t *testing.T) {
+	precompiled, err := sentencepiece.NewPrecompiled(spm.NmtNfkc())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "ｆｕｌｌ", expected: "full"},
+		{input: "ﬁne", expected: "fine"},
+		{input: "①　②", expected: "1 2"},
+		{input: "plain text", expected: "plain text"},
+	}
+	for _, tc := range testCases {
+		normalized, err := precompiled.Normalize(normalizer.NewNormalizedFrom(tc.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if normalized.GetNormalized() != tc.expected {
+			t.Errorf("Expected %q to be normalized to %q, got %q", tc.input, tc.expected, normalized.GetNormalized())
+		}
+	}
+
+	// Each normalized character maps back to the full-width character it comes from.
+	normalized, err := precompiled.Normalize(normalizer.NewNormalizedFrom("aｂc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int{{0, 1}, {1, 4}, {4, 5}}
+	if alignments := normalized.Alignments(); !reflect.DeepEqual(alignments, expected) {
+		t.Errorf("Expected alignments %v, got %v", expected, alignments)
+	}
+
+	if _, err := sentencepiece.NewPrecompiled([]byte{0xFF, 0xFF, 0xFF, 0xFF}); err == nil {
+		t.Error("Expected an error for an invalid charsmap")
+	}
+}
@@ -0,0 +1,262 @@
+package sentencepiece
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/sugarme/tokenizer"
+)
+
+// The penalty of the unknown token, below the lowest score of the vocabulary, as in SentencePiece.
+const unknownPenalty = 10.0
+
+// Struct representing a piece of the vocabulary of a Unigram model, with its log probability.
+// It is stored as a [piece, score] JSON array in the tokenizer.json files.
+type Piece struct {
+	Token string
+	Score float64
+}
+
+// Unigram model of the SentencePiece tokenizers, such as the XLM-RoBERTa one
+// The text is split into the sequence of pieces with the highest total score, found with the Viterbi algorithm.
+// The characters without a piece become the unknown token, consecutive unknown characters being fused into a single token,
+// or the tokens of their UTF-8 bytes with byte fallback.
+type Unigram struct {
+	pieces       []Piece
+	tokenToID    map[string]int
+	unknownID    int
+	byteFallback bool
+	minScore     float64
+	maxPieceLen  int
+}
+
+// Struct holding the best tokenization of the text up to a position.
+type latticeNode struct {
+	id      int
+	start   int
+	score   float64
+	reached bool
+}
+
+var _ tokenizer.Model = (*Unigram)(nil)
+
+// Function to create a Unigram model from its vocabulary
+// unknownID: The index of the unknown token in the vocabulary, -1 if there is none,
+// in which case tokenizing a character without a piece fails
+// byteFallback: Whether to tokenize the unknown characters as their UTF-8 bytes, "<0x41>" for "A", when the vocabulary has them
+func NewUnigram(pieces []Piece, unknownID int, byteFallback bool) (*Unigram, error) {
+	if len(pieces) == 0 {
+		return nil, errors.New("unigram: the vocabulary is empty")
+	}
+	if unknownID < -1 || unknownID >= len(pieces) {
+		return nil, fmt.Errorf("unigram: unknown token id %d out of the vocabulary", unknownID)
+	}
+
+	u := &Unigram{
+		pieces:       pieces,
+		tokenToID:    make(map[string]int, len(pieces)),
+		unknownID:    unknownID,
+		byteFallback: byteFallback,
+		minScore:     math.Inf(1),
+	}
+	for id, piece := range pieces {
+		if _, ok := u.tokenToID[piece.Token]; !ok {
+			u.tokenToID[piece.Token] = id
+		}
+		u.minScore = min(u.minScore, piece.Score)
+		u.maxPieceLen = max(u.maxPieceLen, len(piece.Token))
+	}
+	return u, nil
+}
+
+// Function to split a sequence into the tokens of the vocabulary, with offsets in bytes of the sequence.
+func (u *Unigram) Tokenize(sequence string) ([]tokenizer.Token, error) {
+	pieces, err := u.encode(sequence)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]tokenizer.Token, 0, len(pieces))
+	offset := 0
+	for _, piece := range pieces {
+		offsets := []int{offset, offset + len(piece)}
+		offset += len(piece)
+
+		if id, ok := u.tokenToID[piece]; ok {
+			tokens = append(tokens, tokenizer.Token{Id: id, Value: piece, Offsets: offsets})
+			continue
+		}
+		if byteTokens, ok := u.byteTokens(piece, offsets); ok {
+			tokens = append(tokens, byteTokens...)
+			continue
+		}
+		tokens = append(tokens, tokenizer.Token{Id: u.unknownID, Value: piece, Offsets: offsets})
+	}
+	return tokens, nil
+}
+
+// Private function to find the sequence of pieces with the highest score, the unknown characters being fused.
+func (u *Unigram) encode(sequence string) ([]string, error) {
+	if sequence == "" {
+		return nil, nil
+	}
+	unknownScore := u.minScore - unknownPenalty
+
+	// lattice[i] is the best tokenization of sequence[:i].
+	lattice := make([]latticeNode, len(sequence)+1)
+	lattice[0].reached = true
+	update := func(end int, candidate latticeNode) {
+		if !lattice[end].reached || candidate.score > lattice[end].score {
+			lattice[end] = candidate
+		}
+	}
+
+	for start := 0; start < len(sequence); {
+		if !lattice[start].reached {
+			return nil, fmt.Errorf("unigram: cannot tokenize %q without an unknown token", sequence)
+		}
+		_, charLen := utf8.DecodeRuneInString(sequence[start:])
+
+		hasSingleChar := false
+		limit := min(len(sequence), start+u.maxPieceLen)
+		for end := start; end < limit; {
+			_, size := utf8.DecodeRuneInString(sequence[end:])
+			end += size
+			if end > limit {
+				break
+			}
+			if id, ok := u.tokenToID[sequence[start:end]]; ok {
+				update(end, latticeNode{id: id, start: start, score: lattice[start].score + u.pieces[id].Score, reached: true})
+				hasSingleChar = hasSingleChar || end-start == charLen
+			}
+		}
+		if !hasSingleChar && u.unknownID >= 0 {
+			update(start+charLen, latticeNode{id: u.unknownID, start: start, score: lattice[start].score + unknownScore, reached: true})
+		}
+		start += charLen
+	}
+	if !lattice[len(sequence)].reached {
+		return nil, fmt.Errorf("unigram: cannot tokenize %q without an unknown token", sequence)
+	}
+
+	// The best path is followed backwards, fusing the consecutive unknown tokens.
+	var pieces []string
+	unknownEnd := -1
+	for end := len(sequence); end > 0; end = lattice[end].start {
+		node := lattice[end]
+		if node.id == u.unknownID {
+			if unknownEnd < 0 {
+				unknownEnd = end
+			}
+			continue
+		}
+		if unknownEnd >= 0 {
+			pieces = append(pieces, sequence[end:unknownEnd])
+			unknownEnd = -1
+		}
+		pieces = append(pieces, sequence[node.start:end])
+	}
+	if unknownEnd >= 0 {
+		pieces = append(pieces, sequence[:unknownEnd])
+	}
+
+	for i, j := 0, len(pieces)-1; i < j; i, j = i+1, j-1 {
+		pieces[i], pieces[j] = pieces[j], pieces[i]
+	}
+	return pieces, nil
+}
+
+// Private function to return the byte tokens of an unknown piece, if byte fallback is enabled
+// and the vocabulary has a token for every byte of the piece. The tokens have the offsets of the whole piece.
+func (u *Unigram) byteTokens(piece string, offsets []int) ([]tokenizer.Token, bool) {
+	if !u.byteFallback {
+		return nil, false
+	}
+	tokens := make([]tokenizer.Token, 0, len(piece))
+	for i := 0; i < len(piece); i++ {
+		value := fmt.Sprintf("<0x%02X>", piece[i])
+		id, ok := u.tokenToID[value]
+		if !ok {
+			return nil, false
+		}
+		tokens = append(tokens, tokenizer.Token{Id: id, Value: value, Offsets: offsets})
+	}
+	return tokens, true
+}
+
+// Function to get the id of a token.
+func (u *Unigram) TokenToId(token string) (int, bool) {
+	id, ok := u.tokenToID[token]
+	return id, ok
+}
+
+// Function to get the token of an id.
+func (u *Unigram) IdToToken(id int) (string, bool) {
+	if id < 0 || id >= len(u.pieces) {
+		return "", false
+	}
+	return u.pieces[id].Token, true
+}
+
+// Function to get the ids of the tokens of the vocabulary.
+func (u *Unigram) GetVocab() map[string]int {
+	vocab := make(map[string]int, len(u.tokenToID))
+	for token, id := range u.tokenToID {
+		vocab[token] = id
+	}
+	return vocab
+}
+
+// Function to get the size of the vocabulary.
+func (u *Unigram) GetVocabSize() int {
+	return len(u.pieces)
+}
+
+// Function to save the model as a "unigram.json" file of the directory, prefixed by the optional prefix.
+func (u *Unigram) Save(dir string, prefix ...string) error {
+	name := "unigram.json"
+	if len(prefix) > 0 && prefix[0] != "" {
+		name = prefix[0] + "-" + name
+	}
+
+	var unknownID *int
+	if u.unknownID >= 0 {
+		unknownID = &u.unknownID
+	}
+	data, err := json.Marshal(unigramConfig{Type: "Unigram", UnknownID: unknownID, Vocab: u.pieces, ByteFallback: u.byteFallback})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), data, 0644)
+}
+
+// Function to decode a [piece, score] JSON array.
+func (p *Piece) UnmarshalJSON(data []byte) error {
+	var entry []any
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return err
+	}
+	if len(entry) != 2 {
+		return fmt.Errorf("unigram: invalid vocabulary entry %s", data)
+	}
+	token, ok := entry[0].(string)
+	if !ok {
+		return fmt.Errorf("unigram: invalid vocabulary entry %s", data)
+	}
+	score, ok := entry[1].(float64)
+	if !ok {
+		return fmt.Errorf("unigram: invalid vocabulary entry %s", data)
+	}
+	*p = Piece{Token: token, Score: score}
+	return nil
+}
+
+// Function to encode a piece as a [piece, score] JSON array.
+func (p Piece) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{p.Token, p.Score})
+}
@@ -0,0 +1,97 @@
+package sentencepiece_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/anush008/fastembed-go/sentencepiece"
+	"github.com/sugarme/tokenizer"
+)
+
+func TestUnigramTokenize(t *testing.T) {
+	pieces := []sentencepiece.Piece{
+		{Token: "<unk>", Score: 0},
+		{Token: "▁", Score: -2},
+		{Token: "a", Score: -3},
+		{Token: "b", Score: -3},
+		{Token: "ab", Score: -4},
+		{Token: "abc", Score: -10},
+		{Token: "c", Score: -3},
+		{Token: "<0xC3>", Score: 0},
+		{Token: "<0xA9>", Score: 0},
+	}
+
+	testCases := []struct {
+		name         string
+		byteFallback bool
+		input        string
+		expected     []tokenizer.Token
+	}{
+		{
+			name:  "highest score",
+			input: "▁abc",
+			expected: []tokenizer.Token{
+				{Id: 1, Value: "▁", Offsets: []int{0, 3}},
+				{Id: 4, Value: "ab", Offsets: []int{3, 5}},
+				{Id: 6, Value: "c", Offsets: []int{5, 6}},
+			},
+		},
+		{
+			name:  "fused unknown characters",
+			input: "a零一b",
+			expected: []tokenizer.Token{
+				{Id: 2, Value: "a", Offsets: []int{0, 1}},
+				{Id: 0, Value: "零一", Offsets: []int{1, 7}},
+				{Id: 3, Value: "b", Offsets: []int{7, 8}},
+			},
+		},
+		{
+			name:         "byte fallback",
+			byteFallback: true,
+			input:        "aé",
+			expected: []tokenizer.Token{
+				{Id: 2, Value: "a", Offsets: []int{0, 1}},
+				{Id: 7, Value: "<0xC3>", Offsets: []int{1, 3}},
+				{Id: 8, Value: "<0xA9>", Offsets: []int{1, 3}},
+			},
+		},
+		{
+			name:         "byte fallback without the bytes",
+			byteFallback: true,
+			input:        "零",
+			expected: []tokenizer.Token{
+				{Id: 0, Value: "零", Offsets: []int{0, 3}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			model, err := sentencepiece.NewUnigram(pieces, 0, tc.byteFallback)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := model.Tokenize(tc.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tokens, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, tokens)
+			}
+		})
+	}
+}
+
+func TestUnigramWithoutUnknownToken(t *testing.T) {
+	model, err := sentencepiece.NewUnigram([]sentencepiece.Piece{{Token: "a", Score: -1}}, -1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Tokenize("ab"); err == nil {
+		t.Error("Expected an error for a character without a piece")
+	}
+
+	if _, err := sentencepiece.NewUnigram([]sentencepiece.Piece{{Token: "a", Score: -1}}, 1, false); err == nil {
+		t.Error("Expected an error for an unknown token out of the vocabulary")
+	}
+}
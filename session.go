@@ -0,0 +1,206 @@
+package fastembed
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Enum-type representing the onnxruntime execution providers.
+// The values match the provider names used by onnxruntime and the Python fastembed library.
+type ExecutionProvider string
+
+const (
+	CPUExecutionProvider      ExecutionProvider = "CPUExecutionProvider"
+	CUDAExecutionProvider     ExecutionProvider = "CUDAExecutionProvider"
+	TensorRTExecutionProvider ExecutionProvider = "TensorrtExecutionProvider"
+	CoreMLExecutionProvider   ExecutionProvider = "CoreMLExecutionProvider"
+	DirectMLExecutionProvider ExecutionProvider = "DmlExecutionProvider"
+)
+
+// Enum-type representing the onnxruntime graph optimization levels.
+type GraphOptimizationLevel string
+
+const (
+	GraphOptimizationDisabled GraphOptimizationLevel = "disabled"
+	GraphOptimizationBasic    GraphOptimizationLevel = "basic"
+	GraphOptimizationExtended GraphOptimizationLevel = "extended"
+	GraphOptimizationAll      GraphOptimizationLevel = "all"
+)
+
+// Error returned when an execution provider cannot be enabled on the current onnxruntime build.
+var ErrExecutionProviderUnavailable = errors.New("execution provider unavailable")
+
+// Error returned when an execution provider name is not one of the ExecutionProvider constants.
+var ErrUnknownExecutionProvider = errors.New("unknown execution provider")
+
+// Options to configure the onnxruntime session of a model
+// IntraOpNumThreads: The number of threads used within a graph node, 0 uses the onnxruntime default
+// InterOpNumThreads: The number of threads used across graph nodes, 0 uses the onnxruntime default
+// CPUMemArena: Whether to use the CPU memory arena, defaults to true
+// MemPattern: Whether to use the memory pattern optimization, defaults to true
+// GraphOptimizationLevel: The graph optimization level, defaults to GraphOptimizationAll
+// ExecutionProviders: The execution providers in order of preference
+// DeviceID: The device used by the CUDA, TensorRT and DirectML providers
+// CoreMLFlags: The flags passed to the CoreML provider
+// CPUFallback: Whether to skip providers unavailable on the current build instead of failing
+// NOTE:
+// onnxruntime always runs the nodes not supported by the selected providers on the CPU provider.
+// With CPUFallback, a provider that cannot be enabled at all is skipped, so the model may run entirely on the CPU.
+// The onnxruntime_go binding in use does not expose the graph optimization level,
+// so only the onnxruntime default GraphOptimizationAll is accepted.
+type SessionConfig struct {
+	IntraOpNumThreads      int
+	InterOpNumThreads      int
+	CPUMemArena            *bool
+	MemPattern             *bool
+	GraphOptimizationLevel GraphOptimizationLevel
+	ExecutionProviders     []ExecutionProvider
+	DeviceID               int
+	CoreMLFlags            uint32
+	CPUFallback            bool
+}
+
+// Private function to build the onnxruntime session options from a SessionConfig.
+// The caller must destroy the returned options once the session is created.
+func newSessionOptions(config *SessionConfig) (*ort.SessionOptions, error) {
+	if config == nil {
+		config = &SessionConfig{}
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applySessionConfig(options, config); err != nil {
+		options.Destroy()
+		return nil, err
+	}
+	return options, nil
+}
+
+// Private function to check the optimization level and the provider names.
+// It does not need onnxruntime, so it runs before the model is downloaded.
+func (config *SessionConfig) validate() error {
+	switch config.GraphOptimizationLevel {
+	case "", GraphOptimizationAll:
+	case GraphOptimizationDisabled, GraphOptimizationBasic, GraphOptimizationExtended:
+		return fmt.Errorf("graph optimization level %q is not supported by the onnxruntime binding", config.GraphOptimizationLevel)
+	default:
+		return fmt.Errorf("unknown graph optimization level %q", config.GraphOptimizationLevel)
+	}
+
+	for _, provider := range config.ExecutionProviders {
+		switch provider {
+		case CPUExecutionProvider, CUDAExecutionProvider, TensorRTExecutionProvider,
+			CoreMLExecutionProvider, DirectMLExecutionProvider:
+		default:
+			return fmt.Errorf("%w %q", ErrUnknownExecutionProvider, provider)
+		}
+	}
+	return nil
+}
+
+// Private function to apply the values of a SessionConfig onto the session options.
+func applySessionConfig(options *ort.SessionOptions, config *SessionConfig) error {
+	if err := options.SetIntraOpNumThreads(config.IntraOpNumThreads); err != nil {
+		return err
+	}
+
+	if err := options.SetInterOpNumThreads(config.InterOpNumThreads); err != nil {
+		return err
+	}
+
+	if config.CPUMemArena != nil {
+		if err := options.SetCpuMemArena(*config.CPUMemArena); err != nil {
+			return err
+		}
+	}
+
+	if config.MemPattern != nil {
+		if err := options.SetMemPattern(*config.MemPattern); err != nil {
+			return err
+		}
+	}
+
+	for _, provider := range config.ExecutionProviders {
+		err := appendExecutionProvider(options, provider, config)
+		if err == nil {
+			continue
+		}
+		if config.CPUFallback && errors.Is(err, ErrExecutionProviderUnavailable) {
+			continue
+		}
+		return err
+	}
+	return nil
+}
+
+// Private function to enable a single execution provider on the session options.
+func appendExecutionProvider(options *ort.SessionOptions, provider ExecutionProvider, config *SessionConfig) error {
+	var err error
+	switch provider {
+	case CPUExecutionProvider:
+		// The CPU provider is always registered by onnxruntime.
+		return nil
+	case CUDAExecutionProvider:
+		err = appendCUDA(options, config.DeviceID)
+	case TensorRTExecutionProvider:
+		err = appendTensorRT(options, config.DeviceID)
+	case CoreMLExecutionProvider:
+		err = options.AppendExecutionProviderCoreML(config.CoreMLFlags)
+	case DirectMLExecutionProvider:
+		err = options.AppendExecutionProviderDirectML(config.DeviceID)
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownExecutionProvider, provider)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrExecutionProviderUnavailable, provider, err)
+	}
+	return nil
+}
+
+func appendCUDA(options *ort.SessionOptions, deviceID int) error {
+	cudaOptions, err := ort.NewCUDAProviderOptions()
+	if err != nil {
+		return err
+	}
+	defer cudaOptions.Destroy()
+
+	err = cudaOptions.Update(map[string]string{"device_id": strconv.Itoa(deviceID)})
+	if err != nil {
+		return err
+	}
+	return options.AppendExecutionProviderCUDA(cudaOptions)
+}
+
+func appendTensorRT(options *ort.SessionOptions, deviceID int) error {
+	tensorRTOptions, err := ort.NewTensorRTProviderOptions()
+	if err != nil {
+		return err
+	}
+	defer tensorRTOptions.Destroy()
+
+	err = tensorRTOptions.Update(map[string]string{"device_id": strconv.Itoa(deviceID)})
+	if err != nil {
+		return err
+	}
+	return options.AppendExecutionProviderTensorRT(tensorRTOptions)
+}
+
+// Private function to convert provider names, as accepted by InitOptions.ExecutionProviders.
+func toExecutionProviders(names []string) []ExecutionProvider {
+	providers := make([]ExecutionProvider, len(names))
+	for i, name := range names {
+		providers[i] = ExecutionProvider(name)
+	}
+	return providers
+}
@@ -0,0 +1,191 @@
+package fastembed
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// The environment variable enabling InitOptions.Offline.
+const offlineEnv = "FASTEMBED_OFFLINE"
+
+// Error returned in offline mode, or by LocalSource, when files of the model are missing.
+var ErrModelNotCached = errors.New("model is not cached")
+
+// Interface of the places models are fetched from, set with InitOptions.Source.
+// Fetch returns the directory holding the files of the model, downloading them into options.CacheDir if needed.
+// The options have their defaults set, and a source must not use the network when options.Offline is true.
+type ModelSource interface {
+	Fetch(descriptor ModelDescriptor, options *InitOptions) (string, error)
+}
+
+// Source of the .tar.gz archives of the qdrant-fastembed Google Cloud Storage bucket, the default source
+// The built-in models are downloaded from InitOptions.BaseURL, the other models from the URL of their descriptor.
+// The archives are extracted into a directory of the cache directory named after the model.
+type GCSSource struct{}
+
+// Source of a local directory holding the files of the model, nothing is downloaded.
+type LocalSource struct {
+	Dir string
+}
+
+// Source of a model bundled in a file system, such as an embed.FS, nothing is downloaded
+// FS: The file system
+// Dir: The directory of the file system holding the files of the model, "." if empty
+// NOTE:
+// onnxruntime and the tokenizer read files from the disk, so the files are copied once into a directory of the cache directory
+// named after the model. A model updated in the file system must be given a new name, or removed from the cache.
+type FSSource struct {
+	FS  fs.FS
+	Dir string
+}
+
+// Function to return the cached model directory, downloading and extracting the archive of the model if needed.
+func (GCSSource) Fetch(descriptor ModelDescriptor, options *InitOptions) (string, error) {
+	model := descriptor.Model
+	cacheDir := options.CacheDir
+	modelPath := filepath.Join(cacheDir, string(model))
+	if options.Offline {
+		if err := checkModelFiles(modelPath, descriptor); err != nil {
+			return "", err
+		}
+		return modelPath, nil
+	}
+
+	if _, err := os.Stat(modelPath); !errors.Is(err, fs.ErrNotExist) {
+		return modelPath, nil
+	}
+	if _, err := getBuiltinModelInfo(model); err == nil {
+		descriptor.URL = builtinModelURL(options.BaseURL, model)
+	}
+	if descriptor.URL == "" {
+		return "", fmt.Errorf("model %s is not in %s and has no download URL", model, cacheDir)
+	}
+
+	return newDownloader(options).downloadModel(descriptor, cacheDir)
+}
+
+// Function to return the directory, once checked that it holds the files of the model.
+func (s LocalSource) Fetch(descriptor ModelDescriptor, _ *InitOptions) (string, error) {
+	if err := checkModelFiles(s.Dir, descriptor); err != nil {
+		return "", err
+	}
+	return s.Dir, nil
+}
+
+// Function to return the directory of the cache the model files were copied to, copying them if needed.
+// Every file of the directory of the file system is copied.
+func (s FSSource) Fetch(descriptor ModelDescriptor, options *InitOptions) (string, error) {
+	model := descriptor.Model
+	modelPath := filepath.Join(options.CacheDir, string(model))
+	if _, err := os.Stat(modelPath); !errors.Is(err, fs.ErrNotExist) {
+		return modelPath, nil
+	}
+
+	dir := s.Dir
+	if dir == "" {
+		dir = "."
+	}
+	fsys, err := fs.Sub(s.FS, dir)
+	if err != nil {
+		return "", err
+	}
+	if err := checkModelFilesFS(fsys, "the file system directory "+dir, descriptor); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(options.CacheDir, 0755); err != nil {
+		return "", err
+	}
+	// The files are copied into a temporary directory renamed into place, as done for the downloaded archives.
+	tempDir, err := os.MkdirTemp(options.CacheDir, "."+string(model)+"-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tempDir)
+
+	if err := copyFS(fsys, tempDir); err != nil {
+		return "", fmt.Errorf("copying model %s: %w", model, err)
+	}
+	if err := verifyModelFiles(tempDir, descriptor, options.ProgressFunc); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tempDir, modelPath); err != nil {
+		// Another process may have copied the model in the meantime.
+		if _, statErr := os.Stat(modelPath); statErr == nil {
+			return modelPath, nil
+		}
+		return "", err
+	}
+	return modelPath, nil
+}
+
+// Private function to return the files needed to load a model, with "/" separators
+// The files of the image models if they take pixel values, of the text models otherwise, and those with a digest.
+func (d ModelDescriptor) requiredFiles() []string {
+	files := []string{d.ModelFile}
+	if len(d.InputNames) == 1 && d.InputNames[0] == pixelValuesName {
+		files = append(files, d.PreprocessorConfigFile)
+	} else {
+		files = append(files, d.TokenizerFile, d.ConfigFile, d.TokenizerConfigFile, d.SpecialTokensMapFile)
+	}
+	digestFiles := make([]string, 0, len(d.FileSHA256))
+	for file := range d.FileSHA256 {
+		if !slices.Contains(files, file) {
+			digestFiles = append(digestFiles, file)
+		}
+	}
+	sort.Strings(digestFiles)
+	return append(files, digestFiles...)
+}
+
+// Private function to check that a directory holds the files needed to load a model, and the extra files.
+// The error wraps ErrModelNotCached and lists the missing files.
+func checkModelFiles(dir string, descriptor ModelDescriptor, extraFiles ...string) error {
+	return checkModelFilesFS(os.DirFS(dir), dir, descriptor, extraFiles...)
+}
+
+// Private function to check that a file system holds the files needed to load a model, and the extra files.
+// The location of the file system is used in the error.
+func checkModelFilesFS(fsys fs.FS, location string, descriptor ModelDescriptor, extraFiles ...string) error {
+	var missing []string
+	for _, file := range append(descriptor.requiredFiles(), extraFiles...) {
+		if _, err := fs.Stat(fsys, path.Clean(file)); err != nil {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: model %s is missing %s in %s", ErrModelNotCached, descriptor.Model, strings.Join(missing, ", "), location)
+}
+
+// Private function to copy the regular files and directories of a file system into a directory.
+func copyFS(fsys fs.FS, target string) error {
+	return fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(target, filepath.FromSlash(name))
+		if entry.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+
+		file, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return writeArchiveFile(targetPath, file, 0644)
+	})
+}
@@ -0,0 +1,185 @@
+package fastembed_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+// The files of a text model, as expected by the default descriptor.
+var textModelFiles = []string{
+	"model_optimized.onnx",
+	"tokenizer.json",
+	"config.json",
+	"tokenizer_config.json",
+	"special_tokens_map.json",
+}
+
+// Writes the given files into dir, with their names as content.
+func writeModelFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(file), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLocalSource(t *testing.T) {
+	dir := t.TempDir()
+	writeModelFiles(t, dir, textModelFiles...)
+
+	modelPath, err := fastembed.RetrieveModel(fastembed.BGESmallENV15, &fastembed.InitOptions{Source: fastembed.LocalSource{Dir: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelPath != dir {
+		t.Errorf("Expected %s, got %s", dir, modelPath)
+	}
+
+	emptyDir := t.TempDir()
+	_, err = fastembed.RetrieveModel(fastembed.BGESmallENV15, &fastembed.InitOptions{Source: fastembed.LocalSource{Dir: emptyDir}})
+	if !errors.Is(err, fastembed.ErrModelNotCached) {
+		t.Errorf("Expected a missing model error, got %v", err)
+	}
+}
+
+func TestDescriptorSource(t *testing.T) {
+	dir := t.TempDir()
+	writeModelFiles(t, dir, textModelFiles...)
+	otherDir := t.TempDir()
+	writeModelFiles(t, otherDir, textModelFiles...)
+
+	model := fastembed.EmbeddingModel("descriptor-source-model")
+	err := fastembed.RegisterModel(fastembed.ModelDescriptor{
+		ModelInfo: fastembed.ModelInfo{Model: model, Dim: 4, Pooling: fastembed.MeanPooling},
+		Source:    fastembed.LocalSource{Dir: dir},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modelPath, err := fastembed.RetrieveModel(model, &fastembed.InitOptions{CacheDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelPath != dir {
+		t.Errorf("Expected the source of the descriptor %s, got %s", dir, modelPath)
+	}
+
+	// The source of the options comes first.
+	modelPath, err = fastembed.RetrieveModel(model, &fastembed.InitOptions{CacheDir: t.TempDir(), Source: fastembed.LocalSource{Dir: otherDir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelPath != otherDir {
+		t.Errorf("Expected the source of the options %s, got %s", otherDir, modelPath)
+	}
+}
+
+func TestOfflineMode(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	registerHubModel(t, "hf-offline-test-model")
+	cacheDir := t.TempDir()
+	// A cached model missing its tokenizer files.
+	writeModelFiles(t, filepath.Join(cacheDir, string(fastembed.BGESmallENV15)), "model_optimized.onnx", "config.json")
+
+	testCases := map[string]struct {
+		offline bool
+		env     string
+	}{
+		"option":               {offline: true},
+		"environment variable": {env: "true"},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("FASTEMBED_OFFLINE", tc.env)
+			options := &fastembed.InitOptions{
+				CacheDir:   cacheDir,
+				HTTPClient: server.Client(),
+				BaseURL:    server.URL,
+				Offline:    tc.offline,
+			}
+
+			for _, model := range []fastembed.EmbeddingModel{fastembed.BGESmallENV15, fastembed.BGEBaseENV15} {
+				_, err := fastembed.RetrieveModel(model, options)
+				if !errors.Is(err, fastembed.ErrModelNotCached) {
+					t.Fatalf("Expected a missing model error, got %v", err)
+				}
+				if !strings.Contains(err.Error(), "tokenizer.json") || !strings.Contains(err.Error(), "special_tokens_map.json") {
+					t.Errorf("Expected the error to list the missing files, got %v", err)
+				}
+			}
+
+			_, err := fastembed.RetrieveModel("hf-offline-test-model", &fastembed.InitOptions{
+				CacheDir:   cacheDir,
+				HTTPClient: server.Client(),
+				Offline:    tc.offline,
+				Source:     fastembed.HuggingFaceSource{Repo: "org/model", Endpoint: server.URL},
+			})
+			if !errors.Is(err, fastembed.ErrModelNotCached) {
+				t.Errorf("Expected a missing model error, got %v", err)
+			}
+		})
+	}
+
+	if requests != 0 {
+		t.Errorf("Expected no requests in offline mode, got %d", requests)
+	}
+}
+
+func TestFSSource(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for _, file := range textModelFiles {
+		fsys["models/bge/"+file] = &fstest.MapFile{Data: []byte(file)}
+	}
+	fsys["models/bge/onnx/model.onnx_data"] = &fstest.MapFile{Data: []byte("data")}
+
+	cacheDir := t.TempDir()
+	options := &fastembed.InitOptions{
+		CacheDir: cacheDir,
+		Offline:  true,
+		Source:   fastembed.FSSource{FS: fsys, Dir: "models/bge"},
+	}
+	modelPath, err := fastembed.RetrieveModel(fastembed.BGESmallENV15, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(cacheDir, string(fastembed.BGESmallENV15)); modelPath != expected {
+		t.Errorf("Expected the model in %s, got %s", expected, modelPath)
+	}
+	assertCacheEntries(t, cacheDir, string(fastembed.BGESmallENV15))
+
+	data, err := os.ReadFile(filepath.Join(modelPath, "onnx", "model.onnx_data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("Expected the file to be copied, got %q", data)
+	}
+
+	delete(fsys, "models/bge/tokenizer.json")
+	_, err = fastembed.RetrieveModel(fastembed.BGESmallENV15, &fastembed.InitOptions{
+		CacheDir: t.TempDir(),
+		Source:   fastembed.FSSource{FS: fsys, Dir: "models/bge"},
+	})
+	if !errors.Is(err, fastembed.ErrModelNotCached) || !strings.Contains(err.Error(), "tokenizer.json") {
+		t.Errorf("Expected a missing tokenizer.json error, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package fastembed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The built-in sparse model, to load with NewSparseTextEmbedding.
+const SpladePPENV1 EmbeddingModel = "Qdrant/Splade_PP_en_v1"
+
+// Struct to represent a sparse embedding, the non-zero values and their indices in the vocabulary.
+// The indices are sorted in increasing order.
+type SparseEmbedding struct {
+	Indices []int
+	Values  []float32
+}
+
+// Struct to interface with a sparse, SPLADE-style, model.
+// The model outputs MLM logits of shape (batch size, sequence length, vocabulary size),
+// which are turned into term weights with log(1 + relu(x)) and pooled over the tokens.
+type SparseTextEmbedding struct {
+	*onnxModel
+}
+
+// Function to initialize SpladePPENV1, or a sparse model registered with RegisterModel
+// The Dim of the model descriptor is the size of the vocabulary, its Pooling defaults to MaxPooling as used by SPLADE,
+// MeanPooling being the other supported strategy.
+// Pooling and Normalize in the options are ignored.
+func NewSparseTextEmbedding(options *InitOptions) (*SparseTextEmbedding, error) {
+	// The default model of withDefaultOptions is a dense one.
+	if options == nil || options.Model == "" {
+		return nil, errors.New("a sparse model is required")
+	}
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+
+	descriptor, err := getModelDescriptor(options.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	modelPath, err := retrieveModel(descriptor, options)
+	if err != nil {
+		return nil, err
+	}
+	return loadSparseTextEmbedding(modelPath, descriptor, options)
+}
+
+// Function to initialize a sparse model from a local directory holding the ONNX and tokenizer files.
+// The descriptor does not need to be registered, options.Model and options.CacheDir are ignored.
+func NewSparseTextEmbeddingFromDir(dir string, descriptor ModelDescriptor, options *InitOptions) (*SparseTextEmbedding, error) {
+	descriptor = descriptor.withDefaults()
+	if err := descriptor.validate(); err != nil {
+		return nil, err
+	}
+
+	options = withDefaultOptions(options)
+	if err := validateOptions(options); err != nil {
+		return nil, err
+	}
+	options.Model = descriptor.Model
+	return loadSparseTextEmbedding(dir, descriptor, options)
+}
+
+// Private function to load the files of a sparse model.
+func loadSparseTextEmbedding(modelPath string, descriptor ModelDescriptor, options *InitOptions) (*SparseTextEmbedding, error) {
+	switch descriptor.Pooling {
+	case "":
+		descriptor.Pooling = MaxPooling
+	case MaxPooling, MeanPooling:
+	default:
+		return nil, fmt.Errorf("model %s: unsupported pooling strategy %q for a sparse model", descriptor.Model, descriptor.Pooling)
+	}
+	model, err := loadONNXModel(modelPath, descriptor, options, textGraphSpec([]int{3}, "logits"), 0)
+	if err != nil {
+		return nil, err
+	}
+	return &SparseTextEmbedding{onnxModel: model}, nil
+}
+
+// Function to release the model session when it is no longer needed.
+// Calling Close more than once is a no-op.
+func (s *SparseTextEmbedding) Close() error {
+	return s.close()
+}
+
+// Function to embed a batch of input strings into sparse vectors
+// The batchSize parameter controls the number of inputs to embed in a single batch
+// The batches are processed in parallel, by at most InitOptions.MaxConcurrentBatches workers
+// Default batch size is 256.
+func (s *SparseTextEmbedding) Embed(input []string, batchSize int) ([]SparseEmbedding, error) {
+	return s.EmbedContext(context.Background(), input, batchSize)
+}
+
+// Function to embed a batch of input strings into sparse vectors, stopping early when the context is done.
+// See FlagEmbedding.EmbedContext for the cancellation semantics.
+func (s *SparseTextEmbedding) EmbedContext(ctx context.Context, input []string, batchSize int) ([]SparseEmbedding, error) {
+	if batchSize <= 0 {
+		batchSize = 256
+	}
+	embeddings := make([]SparseEmbedding, len(input))
+	err := s.runBatches(ctx, len(input), batchSize, func(start, end int) error {
+		batchOut, err := s.onnxEmbed(input[start:end])
+		if err != nil {
+			return err
+		}
+		copy(embeddings[start:end], batchOut)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// Function to embed a single input string prefixed with the query prefix of the model.
+func (s *SparseTextEmbedding) QueryEmbed(input string) (SparseEmbedding, error) {
+	data, err := s.Embed([]string{s.descriptor.QueryPrefix + input}, 1)
+	if err != nil {
+		return SparseEmbedding{}, err
+	}
+	return data[0], nil
+}
+
+// Function to embed strings prefixed with the passage prefix of the model.
+func (s *SparseTextEmbedding) PassageEmbed(input []string, batchSize int) ([]SparseEmbedding, error) {
+	processedInput := make([]string, len(input))
+	for i, v := range input {
+		processedInput[i] = s.descriptor.PassagePrefix + v
+	}
+	return s.Embed(processedInput, batchSize)
+}
+
+// Private function to embed a batch of input strings.
+func (s *SparseTextEmbedding) onnxEmbed(input []string) ([]SparseEmbedding, error) {
+	batch, err := s.encode(input)
+	if err != nil {
+		return nil, err
+	}
+
+	shape := batch.tokenShape(s.descriptor.Dim)
+	data, err := s.run(batch, shape)
+	if err != nil {
+		return nil, err
+	}
+
+	return getSparseEmbeddings(data, shape, batch.inputMask, s.descriptor.Pooling), nil
+}
+
+// Private function to return the sparse embeddings from flattened MLM logits with the given dimensions
+// The padding is skipped and the logits of the tokens are pooled into a single vocabulary-wide row per input.
+// log(1 + relu(x)) being increasing, the max pooling is done on the logits, transformed once pooled.
+func getSparseEmbeddings(data []float32, dimensions []int64, mask []int64, pooling PoolingStrategy) []SparseEmbedding {
+	x, y, z := dimensions[0], dimensions[1], dimensions[2]
+	embeddings := make([]SparseEmbedding, x)
+	weights := make([]float32, z)
+	var i, j int64
+	for i = 0; i < x; i++ {
+		clear(weights)
+		tokens := 0
+		for j = 0; j < y; j++ {
+			if mask[i*y+j] == 0 {
+				continue
+			}
+			tokens++
+			startIndex := (i*y + j) * z
+			for k, val := range data[startIndex : startIndex+z] {
+				if pooling == MaxPooling {
+					weights[k] = max(weights[k], val)
+				} else {
+					weights[k] += float32(math.Log1p(float64(max(val, 0))))
+				}
+			}
+		}
+
+		for k, val := range weights {
+			if pooling == MaxPooling {
+				weights[k] = float32(math.Log1p(float64(val)))
+			} else if tokens > 0 {
+				weights[k] = val / float32(tokens)
+			}
+		}
+		embeddings[i] = toSparse(weights)
+	}
+	return embeddings
+}
+
+// Private function to keep the non-zero values of a dense vector.
+func toSparse(v []float32) SparseEmbedding {
+	embedding := SparseEmbedding{
+		Indices: make([]int, 0),
+		Values:  make([]float32, 0),
+	}
+	for i, val := range v {
+		if val > 0 {
+			embedding.Indices = append(embedding.Indices, i)
+			embedding.Values = append(embedding.Values, val)
+		}
+	}
+	return embedding
+}
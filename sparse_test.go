@@ -0,0 +1,101 @@
+package fastembed_test
+
+import (
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+func TestGetSparseEmbeddings(t *testing.T) {
+	// One input of three tokens over a vocabulary of four, the last token being padding.
+	logits := []float32{
+		-1, 2, 0, 0.5,
+		-3, 1, 0, 3,
+		9, 9, 9, 9,
+	}
+	mask := []int64{1, 1, 0}
+
+	result := fastembed.GetSparseEmbeddings(logits, []int64{1, 3, 4}, mask, fastembed.MaxPooling)
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 embedding, got %d", len(result))
+	}
+
+	expectedIndices := []int{1, 3}
+	expectedValues := []float32{float32(math.Log1p(2)), float32(math.Log1p(3))}
+	if !reflect.DeepEqual(result[0].Indices, expectedIndices) {
+		t.Errorf("Expected indices %v, got %v", expectedIndices, result[0].Indices)
+	}
+	for i, v := range expectedValues {
+		if math.Abs(float64(result[0].Values[i]-v)) > 1e-6 {
+			t.Errorf("Value %d mismatch: expected %.6f, got %.6f", i, v, result[0].Values[i])
+		}
+	}
+}
+
+func TestGetSparseEmbeddingsMeanPooling(t *testing.T) {
+	// Two inputs of two tokens over a vocabulary of two, the second input being padded.
+	logits := []float32{
+		1, -1,
+		3, 2,
+		2, 0,
+		9, 9,
+	}
+	mask := []int64{1, 1, 1, 0}
+
+	result := fastembed.GetSparseEmbeddings(logits, []int64{2, 2, 2}, mask, fastembed.MeanPooling)
+	expected := []fastembed.SparseEmbedding{
+		{Indices: []int{0, 1}, Values: []float32{float32((math.Log1p(1) + math.Log1p(3)) / 2), float32(math.Log1p(2) / 2)}},
+		{Indices: []int{0}, Values: []float32{float32(math.Log1p(2))}},
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(result[i].Indices, expected[i].Indices) {
+			t.Fatalf("Input %d: expected indices %v, got %v", i, expected[i].Indices, result[i].Indices)
+		}
+		for j, v := range expected[i].Values {
+			if math.Abs(float64(result[i].Values[j]-v)) > 1e-6 {
+				t.Errorf("Input %d: value %d mismatch: expected %.6f, got %.6f", i, j, v, result[i].Values[j])
+			}
+		}
+	}
+}
+
+func TestSparseTextEmbedding(t *testing.T) {
+	sparse, err := fastembed.NewSparseTextEmbedding(&fastembed.InitOptions{Model: fastembed.SpladePPENV1})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer sparse.Close()
+
+	result, err := sparse.Embed([]string{"hello world", ""}, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("Expected 2 embeddings, got %d", len(result))
+	}
+
+	embedding := result[0]
+	if len(embedding.Indices) == 0 || len(embedding.Indices) != len(embedding.Values) {
+		t.Fatalf("Expected as many indices as values, got %d and %d", len(embedding.Indices), len(embedding.Values))
+	}
+	for i := 1; i < len(embedding.Indices); i++ {
+		if embedding.Indices[i] <= embedding.Indices[i-1] {
+			t.Fatalf("Expected increasing indices, got %v", embedding.Indices)
+		}
+	}
+	// The input terms weigh in their own embedding, "hello" and "world" being 7592 and 2088 in the BERT vocabulary.
+	for _, index := range []int{7592, 2088} {
+		if !slices.Contains(embedding.Indices, index) {
+			t.Errorf("Expected the term %d in the embedding", index)
+		}
+	}
+}
+
+func TestNewSparseTextEmbeddingRequiresModel(t *testing.T) {
+	if _, err := fastembed.NewSparseTextEmbedding(nil); err == nil {
+		t.Error("Expected an error without a sparse model")
+	}
+}
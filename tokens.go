@@ -0,0 +1,75 @@
+package fastembed
+
+import (
+	"context"
+
+	"github.com/sugarme/tokenizer"
+)
+
+// Struct holding what the tokenizer made of an input string
+// TokenCount: The number of tokens of the input, the special tokens included, before truncation
+// Truncated: Whether the input had more than InitOptions.MaxLength tokens, the tokens past it not being embedded
+type InputMetadata struct {
+	TokenCount int
+	Truncated  bool
+}
+
+// Struct holding the tokens of an input string, as fed to the model
+// IDs: The ids of the tokens, the special tokens included
+// Tokens: The tokens
+// Offsets: The byte offsets of each token in the input, [0, 0] for the special tokens
+// Truncated: Whether the input had more than InitOptions.MaxLength tokens, the tokens past it being dropped
+type TokenizedInput struct {
+	IDs       []int
+	Tokens    []string
+	Offsets   [][]int
+	Truncated bool
+}
+
+// Function to count the tokens of input strings, the special tokens included, before truncation
+// An input with more than InitOptions.MaxLength tokens is truncated when embedded.
+func (f *FlagEmbedding) CountTokens(input []string) ([]int, error) {
+	_, tokenCounts, err := f.encodeEach(context.Background(), input, 256)
+	if err != nil {
+		return nil, err
+	}
+	return tokenCounts, nil
+}
+
+// Function to tokenize input strings as done by Embed, truncation included.
+func (f *FlagEmbedding) Tokenize(input []string) ([]TokenizedInput, error) {
+	encodings, tokenCounts, err := f.encodeEach(context.Background(), input, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenized := make([]TokenizedInput, len(input))
+	for i, encoding := range encodings {
+		tokenized[i] = TokenizedInput{
+			IDs:       encoding.GetIds(),
+			Tokens:    encoding.GetTokens(),
+			Offsets:   encoding.GetOffsets(),
+			Truncated: tokenCounts[i] > encoding.Len(),
+		}
+	}
+	return tokenized, nil
+}
+
+// Function to embed a batch of input strings as Embed does, also returning the metadata of each input.
+func (f *FlagEmbedding) EmbedWithMetadata(input []string, batchSize int) ([]([]float32), []InputMetadata, error) {
+	return f.EmbedWithMetadataContext(context.Background(), input, batchSize)
+}
+
+// Function to embed a batch of input strings as EmbedContext does, also returning the metadata of each input.
+func (f *FlagEmbedding) EmbedWithMetadataContext(ctx context.Context, input []string, batchSize int) ([]([]float32), []InputMetadata, error) {
+	return f.embed(ctx, input, batchSize, f.normalize)
+}
+
+// Private function to return the metadata of unpadded encodings, given the number of tokens of their inputs.
+func newInputMetadata(encodings []tokenizer.Encoding, tokenCounts []int) []InputMetadata {
+	metadata := make([]InputMetadata, len(encodings))
+	for i, encoding := range encodings {
+		metadata[i] = InputMetadata{TokenCount: tokenCounts[i], Truncated: tokenCounts[i] > encoding.Len()}
+	}
+	return metadata
+}
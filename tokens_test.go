@@ -0,0 +1,119 @@
+package fastembed_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+	"github.com/anush008/fastembed-go/sentencepiece"
+)
+
+func TestTokenize(t *testing.T) {
+	words := strings.Fields("one two three four five six")
+	fe, stop, err := fastembed.NewTokenizerOnlyEmbedding(writeWordLevelTokenizer(t, words), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	input := []string{"one two", "one two three four five six"}
+
+	counts, err := fe.CountTokens(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []int{4, 8}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("Expected token counts %v, got %v", expected, counts)
+	}
+
+	tokenized, err := fe.Tokenize(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []fastembed.TokenizedInput{
+		{
+			IDs:     []int{1, 3, 4, 2},
+			Tokens:  []string{"[CLS]", "one", "two", "[SEP]"},
+			Offsets: [][]int{{0, 0}, {0, 3}, {4, 7}, {0, 0}},
+		},
+		{
+			IDs:       []int{1, 3, 4, 5, 2},
+			Tokens:    []string{"[CLS]", "one", "two", "three", "[SEP]"},
+			Offsets:   [][]int{{0, 0}, {0, 3}, {4, 7}, {8, 13}, {0, 0}},
+			Truncated: true,
+		},
+	}
+	if !reflect.DeepEqual(tokenized, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, tokenized)
+	}
+}
+
+func TestEncodeBatchMetadata(t *testing.T) {
+	words := strings.Fields("one two three four five six")
+	fe, stop, err := fastembed.NewTokenizerOnlyEmbedding(writeWordLevelTokenizer(t, words), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// The padding of the short input is not counted.
+	_, metadata, err := fastembed.EncodeBatch(fe, []string{"one", "one two three four five six"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMetadata := []fastembed.InputMetadata{{TokenCount: 3}, {TokenCount: 8, Truncated: true}}
+	if !reflect.DeepEqual(metadata, expectedMetadata) {
+		t.Errorf("Expected metadata %+v, got %+v", expectedMetadata, metadata)
+	}
+}
+
+func TestEmbedWithMetadata(t *testing.T) {
+	fe, err := fastembed.NewFlagEmbedding(&fastembed.InitOptions{MaxLength: 16})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer fe.Close()
+
+	input := []string{"hello world", strings.Repeat("a long input ", 20)}
+	embeddings, metadata, err := fe.EmbedWithMetadata(input, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(embeddings) != len(input) || len(metadata) != len(input) {
+		t.Fatalf("Expected %d embeddings and metadata, got %d and %d", len(input), len(embeddings), len(metadata))
+	}
+	if metadata[0] != (fastembed.InputMetadata{TokenCount: 4}) {
+		t.Errorf("Expected 4 tokens without truncation, got %+v", metadata[0])
+	}
+	if !metadata[1].Truncated || metadata[1].TokenCount <= 16 {
+		t.Errorf("Expected the long input to be truncated, got %+v", metadata[1])
+	}
+}
+
+// The sentencepiece tokenizer of the multilingual e5 model is the one of XLM-RoBERTa,
+// its ids are checked against the ones of the Hugging Face tokenizer.
+func TestXLMRobertaTokenizer(t *testing.T) {
+	modelPath, err := fastembed.RetrieveModel(fastembed.MLE5Large, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	tk, err := sentencepiece.FromFile(filepath.Join(modelPath, "tokenizer.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string][]int{
+		"Hello world":           {0, 35378, 8999, 2},
+		"Hello, my dog is cute": {0, 35378, 4, 759, 10269, 83, 99942, 2},
+	}
+	for input, expected := range testCases {
+		encoding, err := tk.EncodeSingle(input, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := encoding.GetIds(); !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected ids %v for %q, got %v", expected, input, ids)
+		}
+	}
+}
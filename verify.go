@@ -0,0 +1,116 @@
+package fastembed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Error returned when a downloaded or cached model does not match the SHA-256 digests of its descriptor.
+// File is the path of the mismatching file relative to the model directory, or empty for the downloaded archive.
+type ChecksumError struct {
+	Model    EmbeddingModel
+	File     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("model %s: archive checksum mismatch: expected sha256 %s, got %s", e.Model, e.Expected, e.Actual)
+	}
+	return fmt.Sprintf("model %s: checksum mismatch for %s: expected sha256 %s, got %s", e.Model, e.File, e.Expected, e.Actual)
+}
+
+// Function to check that a model in the cache directory matches the FileSHA256 digests of its descriptor.
+// Returns a *ChecksumError if a file does not match, and an error if a file is missing.
+// A model without file digests is only checked for the presence of its ONNX file.
+func VerifyModel(cacheDir string, model EmbeddingModel) error {
+	descriptor, err := getModelDescriptor(model)
+	if err != nil {
+		return err
+	}
+
+	modelPath := filepath.Join(cacheDir, string(model))
+	if _, err := os.Stat(filepath.Join(modelPath, descriptor.ModelFile)); err != nil {
+		return fmt.Errorf("model %s: %w", model, err)
+	}
+	return verifyModelFiles(modelPath, descriptor, nil)
+}
+
+// Private function to check the files of a model directory against the FileSHA256 digests of the descriptor.
+// The files are checked in name order, so the reported mismatch does not depend on the map order.
+// The progress is reported for all the files at once, if the model has file digests.
+func verifyModelFiles(modelPath string, descriptor ModelDescriptor, progress ProgressFunc) error {
+	if len(descriptor.FileSHA256) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(descriptor.FileSHA256))
+	var size int64
+	for name := range descriptor.FileSHA256 {
+		names = append(names, name)
+		info, err := os.Stat(filepath.Join(modelPath, filepath.FromSlash(name)))
+		if err != nil {
+			return fmt.Errorf("model %s: %w", descriptor.Model, err)
+		}
+		size += info.Size()
+	}
+	sort.Strings(names)
+
+	verifyProgress := &progressWriter{
+		progress: Progress{Model: descriptor.Model, Phase: PhaseVerify, File: string(descriptor.Model), Total: size},
+		report:   progress,
+	}
+	verifyProgress.start()
+	for _, name := range names {
+		digest, err := fileSHA256(filepath.Join(modelPath, filepath.FromSlash(name)), verifyProgress)
+		if err != nil {
+			return fmt.Errorf("model %s: %w", descriptor.Model, err)
+		}
+		if err := checkDigest(descriptor.Model, name, descriptor.FileSHA256[name], digest); err != nil {
+			return err
+		}
+	}
+	verifyProgress.complete()
+	return nil
+}
+
+// Private function to compute the hex encoded SHA-256 digest of a file, writing its content to progress if not nil.
+func fileSHA256(path string, progress io.Writer) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	var writer io.Writer = hash
+	if progress != nil {
+		writer = io.MultiWriter(hash, progress)
+	}
+	if _, err := io.Copy(writer, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Private function to compare hex encoded digests.
+func checkDigest(model EmbeddingModel, file, expected, actual string) error {
+	if !strings.EqualFold(expected, actual) {
+		return &ChecksumError{Model: model, File: file, Expected: strings.ToLower(expected), Actual: actual}
+	}
+	return nil
+}
+
+// Private function to check that a digest is a hex encoded SHA-256.
+func validateDigest(digest string) error {
+	if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+		return fmt.Errorf("invalid sha256 digest %q", digest)
+	}
+	return nil
+}
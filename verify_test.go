@@ -0,0 +1,132 @@
+package fastembed_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fastembed "github.com/anush008/fastembed-go"
+)
+
+// Returns the hex encoded SHA-256 digest of data.
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func TestExtractModelChecksums(t *testing.T) {
+	archive := buildArchive(t, []tar.Header{
+		{Name: "test-model/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "test-model/config.json", Typeflag: tar.TypeReg, Mode: 0644},
+	}, map[string]string{
+		"test-model/config.json": "{}",
+	})
+	wrongDigest := sha256Hex([]byte("something else"))
+
+	testCases := []struct {
+		name          string
+		archiveDigest string
+		fileDigest    string
+		mismatch      bool
+		mismatchFile  string
+	}{
+		{name: "valid digests", archiveDigest: sha256Hex(archive), fileDigest: sha256Hex([]byte("{}"))},
+		{name: "upper case digests", archiveDigest: strings.ToUpper(sha256Hex(archive))},
+		{name: "archive mismatch", archiveDigest: wrongDigest, mismatch: true},
+		{name: "file mismatch", fileDigest: wrongDigest, mismatch: true, mismatchFile: "config.json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			descriptor := testModelDescriptor
+			descriptor.ArchiveSHA256 = tc.archiveDigest
+			if tc.fileDigest != "" {
+				descriptor.FileSHA256 = map[string]string{"config.json": tc.fileDigest}
+			}
+
+			cacheDir := t.TempDir()
+			_, err := fastembed.ExtractModel(bytes.NewReader(archive), descriptor, cacheDir)
+			if !tc.mismatch {
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertCacheEntries(t, cacheDir, "test-model")
+				return
+			}
+
+			var checksumErr *fastembed.ChecksumError
+			if !errors.As(err, &checksumErr) {
+				t.Fatalf("Expected a checksum error, got %v", err)
+			}
+			if checksumErr.File != tc.mismatchFile {
+				t.Errorf("Expected the mismatch on %q, got %q", tc.mismatchFile, checksumErr.File)
+			}
+			if checksumErr.Expected != wrongDigest {
+				t.Errorf("Expected digest %s, got %s", wrongDigest, checksumErr.Expected)
+			}
+			assertCacheEntries(t, cacheDir)
+		})
+	}
+}
+
+func TestVerifyModel(t *testing.T) {
+	content := []byte("onnx")
+	descriptor := fastembed.ModelDescriptor{
+		ModelInfo:  fastembed.ModelInfo{Model: "verify-test-model", Dim: 4},
+		FileSHA256: map[string]string{"model_optimized.onnx": sha256Hex(content)},
+	}
+	if err := fastembed.RegisterModel(descriptor); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	if err := fastembed.VerifyModel(cacheDir, descriptor.Model); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing model error, got %v", err)
+	}
+
+	modelPath := filepath.Join(cacheDir, string(descriptor.Model))
+	if err := os.MkdirAll(modelPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(modelPath, "model_optimized.onnx"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fastembed.VerifyModel(cacheDir, descriptor.Model); err != nil {
+		t.Errorf("Expected the model to verify, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(modelPath, "model_optimized.onnx"), []byte("corrupted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var checksumErr *fastembed.ChecksumError
+	if err := fastembed.VerifyModel(cacheDir, descriptor.Model); !errors.As(err, &checksumErr) {
+		t.Errorf("Expected a checksum error, got %v", err)
+	}
+}
+
+func TestBuiltinModelDigests(t *testing.T) {
+	for _, model := range fastembed.BuiltinModelNames() {
+		descriptor, err := fastembed.GetModelDescriptor(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The archive of a GCS model is checked while downloading, the files of every model after.
+		if descriptor.URL != "" && descriptor.ArchiveSHA256 == "" {
+			t.Errorf("Model %s has no archive digest", model)
+		}
+		if _, ok := descriptor.FileSHA256[descriptor.ModelFile]; !ok {
+			t.Errorf("Model %s has no digest for %s", model, descriptor.ModelFile)
+		}
+		for name, digest := range descriptor.FileSHA256 {
+			if decoded, err := hex.DecodeString(digest); err != nil || len(decoded) != sha256.Size {
+				t.Errorf("Model %s: invalid digest %q for %s", model, digest, name)
+			}
+		}
+	}
+}